@@ -56,6 +56,7 @@ export default defineConfig({
         items: [
           { text: "CLI Commands", link: "/cli" },
           { text: "Configuration Schema", link: "/schema" },
+          { text: "Go Library", link: "/library" },
         ],
       },
       {
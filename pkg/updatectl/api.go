@@ -0,0 +1,176 @@
+package updatectl
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIConfig enables the HTTP control API in the watch daemon.
+type APIConfig struct {
+	Addr  string `yaml:"addr" json:"addr" toml:"addr"`    // Address to listen on, e.g. "127.0.0.1:9100"
+	Token string `yaml:"token" json:"token" toml:"token"` // Bearer token every request must carry
+}
+
+// defaultAPIHistoryLimit is how many deploys GET /projects/{name}/history
+// returns without a limit parameter.
+const defaultAPIHistoryLimit = 20
+
+// APIServer serves the control API: project status at GET /projects, deploy
+// history at GET /projects/{name}/history, and POST /projects/{name}/deploy,
+// which queues the project for an immediate check by the watch loop, the
+// same way a webhook does.
+type APIServer struct {
+	mu       sync.Mutex
+	config   Config
+	triggers chan string
+}
+
+// NewAPIServer returns an API server for the projects in config.
+func NewAPIServer(config Config) *APIServer {
+	return &APIServer{config: config, triggers: make(chan string, 64)}
+}
+
+// SetConfig replaces the projects and token used after the config is
+// reloaded.
+func (s *APIServer) SetConfig(config Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// Triggers returns the names of projects queued for a deploy through the API.
+func (s *APIServer) Triggers() <-chan string {
+	return s.triggers
+}
+
+func (s *APIServer) current() Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config
+}
+
+// Serve listens on addr until ctx is done, then shuts the server down.
+func (s *APIServer) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /projects", s.listProjects)
+	mux.HandleFunc("GET /projects/{name}/history", s.projectHistory)
+	mux.HandleFunc("POST /projects/{name}/deploy", s.deployProject)
+	srv := &http.Server{Addr: addr, Handler: s.authorized(mux), ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// authorized rejects requests without the configured bearer token, and all
+// requests once a reload has removed the api setting.
+func (s *APIServer) authorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api := s.current().API
+		if api == nil {
+			// The listener stays up until watch restarts, but the API is off.
+			writeAPIError(w, http.StatusServiceUnavailable, "the API is disabled in the config")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		want := api.Token
+		if !ok || want == "" || subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+			Logger.Warn("Rejected API request", "remote", r.RemoteAddr, "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="updatectl"`)
+			writeAPIError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *APIServer) listProjects(w http.ResponseWriter, r *http.Request) {
+	state, err := LoadState()
+	if err != nil {
+		Logger.Warn("Failed to load state", "error", err)
+	}
+	config := s.current()
+	statuses := make([]ProjectStatus, 0, len(config.Projects))
+	for _, p := range config.Projects {
+		statuses = append(statuses, ReadProjectStatus(r.Context(), p, state))
+	}
+	writeAPIJSON(w, http.StatusOK, statuses)
+}
+
+func (s *APIServer) projectHistory(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.project(w, r)
+	if !ok {
+		return
+	}
+	limit := defaultAPIHistoryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a number, 0 for all")
+			return
+		}
+		limit = n
+	}
+	events, err := ReadHistory(p.Name, limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if events == nil {
+		events = []HistoryEvent{}
+	}
+	writeAPIJSON(w, http.StatusOK, events)
+}
+
+func (s *APIServer) deployProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.project(w, r)
+	if !ok {
+		return
+	}
+	select {
+	case s.triggers <- p.Name:
+	default:
+		// The queue is full, so checks are already pending.
+	}
+	Logger.Info("API deploy requested, update queued", "project", p.Name, "remote", r.RemoteAddr)
+	writeAPIJSON(w, http.StatusAccepted, map[string]string{"project": p.Name, "status": "queued"})
+}
+
+// project returns the project named in the request path, answering 404 if
+// there is none.
+func (s *APIServer) project(w http.ResponseWriter, r *http.Request) (Project, bool) {
+	name := r.PathValue("name")
+	for _, p := range s.current().Projects {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "no project named "+name)
+	return Project{}, false
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,40 @@
+package updatectl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIAuthorizedAfterReload(t *testing.T) {
+	useTempConfig(t)
+	config := Config{API: &APIConfig{Addr: "127.0.0.1:0", Token: "secret"}}
+	s := NewAPIServer(config)
+	handler := s.authorized(http.HandlerFunc(s.listProjects))
+
+	get := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/projects", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := get("secret"); code != http.StatusOK {
+		t.Errorf("with token: status %d, want %d", code, http.StatusOK)
+	}
+	if code := get("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("with wrong token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	// A reload that removes api must not leave the listener panicking.
+	config.API = nil
+	s.SetConfig(config)
+	for _, token := range []string{"secret", ""} {
+		if code := get(token); code != http.StatusServiceUnavailable {
+			t.Errorf("after api was removed, token %q: status %d, want %d", token, code, http.StatusServiceUnavailable)
+		}
+	}
+}
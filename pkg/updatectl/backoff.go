@@ -0,0 +1,100 @@
+package updatectl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// failureBackoffAfter is how many consecutive failed checks a project may
+// have before it is checked less often.
+const failureBackoffAfter = 3
+
+// maxFailureBackoff caps how long the checks of a failing project are
+// spread apart. A project whose schedule is sparser than this keeps it.
+const maxFailureBackoff = time.Hour
+
+// failureStreaks counts the consecutive failed checks of each project in
+// this process. It is not persisted, so a restarted daemon retries right away.
+var failureStreaks = struct {
+	sync.Mutex
+	counts map[string]int
+}{counts: make(map[string]int)}
+
+// recordCheckResult updates p's failure streak with the outcome of a check
+// and returns the new streak length. A success resets it; checks cut short
+// by shutdown don't count either way.
+func recordCheckResult(name string, err error) int {
+	if errors.Is(err, ErrUnfinished) {
+		return 0
+	}
+	failureStreaks.Lock()
+	defer failureStreaks.Unlock()
+	if err == nil {
+		delete(failureStreaks.counts, name)
+		return 0
+	}
+	failureStreaks.counts[name]++
+	return failureStreaks.counts[name]
+}
+
+// failureStreak returns the number of consecutive failed checks of the
+// project called name.
+func failureStreak(name string) int {
+	failureStreaks.Lock()
+	defer failureStreaks.Unlock()
+	return failureStreaks.counts[name]
+}
+
+// logsFailure reports whether the failures-th consecutive failure of a
+// project is logged. Past failureBackoffAfter only every power of two is,
+// so a project that stays broken fills the log ever more slowly.
+func logsFailure(failures int) bool {
+	return failures <= failureBackoffAfter || failures&(failures-1) == 0
+}
+
+// hushOutput returns where the output of the next check of the project called
+// name goes. If another failure of the project wouldn't be logged, it is a
+// buffer that the returned function only writes to out when the check didn't
+// fail after all; otherwise it is out itself.
+func hushOutput(name string, out io.Writer) (io.Writer, func(failed bool)) {
+	if logsFailure(failureStreak(name) + 1) {
+		return out, func(bool) {}
+	}
+	var buf bytes.Buffer
+	return &buf, func(failed bool) {
+		if !failed {
+			out.Write(buf.Bytes())
+		}
+	}
+}
+
+// failureBackoff returns how much later than usual p's next check should be
+// because of its failure streak. The gap between checks doubles with every
+// failure past failureBackoffAfter, up to maxFailureBackoff.
+func (c Config) failureBackoff(p Project) time.Duration {
+	failures := failureStreak(p.Name)
+	if failures < failureBackoffAfter {
+		return 0
+	}
+
+	base := c.ProjectInterval(p)
+	if base <= 0 {
+		// Cron-only configs have no interval to double.
+		base = time.Minute
+	}
+	if base >= maxFailureBackoff {
+		return 0
+	}
+	gap := base
+	for range failures - failureBackoffAfter + 1 {
+		gap *= 2
+		if gap >= maxFailureBackoff {
+			gap = maxFailureBackoff
+			break
+		}
+	}
+	return gap - base
+}
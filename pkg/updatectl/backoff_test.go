@@ -0,0 +1,43 @@
+package updatectl
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunCycleHushesRepeatedFailures(t *testing.T) {
+	useTempConfig(t)
+	p := Project{Name: "broken", Type: "pm2", Path: filepath.Join(t.TempDir(), "missing")}
+	config := Config{Concurrency: 1, Projects: []Project{p}}
+	t.Cleanup(func() { recordCheckResult(p.Name, nil) })
+
+	var out bytes.Buffer
+	oldOutput := Output
+	Output = &out
+	t.Cleanup(func() { Output = oldOutput })
+
+	u := NewUpdater(Options{Runner: &fakeRunner{}})
+	var logged []int
+	for cycle := 1; cycle <= 10; cycle++ {
+		out.Reset()
+		_, err := u.RunCycleResults(context.Background(), config)
+		if err == nil {
+			t.Fatalf("cycle %d: RunCycleResults succeeded", cycle)
+		}
+		shown := strings.Contains(out.String(), "Path not found")
+		reported := ReportedErrors(err) != nil
+		if shown != reported {
+			t.Errorf("cycle %d: output shown = %v but error reported = %v", cycle, shown, reported)
+		}
+		if shown {
+			logged = append(logged, cycle)
+		}
+	}
+	if want := []int{1, 2, 3, 4, 8}; !slices.Equal(logged, want) {
+		t.Errorf("failures logged in cycles %v, want %v", logged, want)
+	}
+}
@@ -0,0 +1,84 @@
+package updatectl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultBuildLogs is how many build logs are kept per project when the
+// config doesn't say.
+const defaultBuildLogs = 10
+
+// BuildLogTimeFormat names build log files so they sort chronologically.
+const BuildLogTimeFormat = "20060102-150405.000"
+
+// buildLogs returns how many build logs to keep per project.
+func (c Config) buildLogs() int {
+	if c.BuildLogs > 0 {
+		return c.BuildLogs
+	}
+	return defaultBuildLogs
+}
+
+// buildLogDir returns the directory holding the named project's build logs:
+// logs/<project>/ in the StateDir.
+func buildLogDir(name string) string {
+	name = strings.NewReplacer("/", "_", `\`, "_").Replace(name)
+	return filepath.Join(StateDir(), "logs", name)
+}
+
+// createBuildLog opens a new timestamped build log for p and writes a header
+// naming the command. Older logs beyond keep are removed.
+func createBuildLog(p Project, keep int) (*os.File, error) {
+	dir := buildLogDir(p.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create build log directory: %w", err)
+	}
+	now := time.Now()
+	f, err := os.Create(filepath.Join(dir, now.Format(BuildLogTimeFormat)+".log"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build log: %w", err)
+	}
+	fmt.Fprintf(f, "# %s: %s\n# started %s\n", p.Name, p.BuildCommand, now.Format(time.RFC3339))
+	pruneBuildLogs(p.Name, keep)
+	return f, nil
+}
+
+// finishBuildLog records the outcome of the build at the end of f and
+// closes it.
+func finishBuildLog(f *os.File, start time.Time, buildErr error) {
+	result := "succeeded"
+	if buildErr != nil {
+		result = "failed: " + buildErr.Error()
+	}
+	fmt.Fprintf(f, "# %s after %s\n", result, time.Since(start).Round(time.Millisecond))
+	f.Close()
+}
+
+// ListBuildLogs returns the paths of the named project's build logs, newest
+// first.
+func ListBuildLogs(name string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(buildLogDir(name), "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+	slices.Reverse(paths)
+	return paths, nil
+}
+
+// pruneBuildLogs removes all but the newest keep build logs of the named
+// project.
+func pruneBuildLogs(name string, keep int) {
+	paths, err := ListBuildLogs(name)
+	if err != nil || len(paths) <= keep {
+		return
+	}
+	for _, path := range paths[keep:] {
+		os.Remove(path)
+	}
+}
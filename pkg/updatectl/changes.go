@@ -0,0 +1,90 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// previewChanges fetches the branch or tag p deploys and writes the commits that
+// are about to be deployed to out, before the checkout is touched. It
+// reports whether the update should go ahead, which is only false when
+// running interactively and the user declines. Whether anything actually
+// changed is still decided afterwards by comparing HEAD.
+func previewChanges(ctx context.Context, config Config, p Project, log *slog.Logger, out io.Writer) (bool, error) {
+	var rev string
+	output, err := retryGit(ctx, config, p, log, func() (output []byte, err error) {
+		rev, output, err = gitFetchUpstream(ctx, p)
+		return output, err
+	})
+	if err != nil {
+		log.Error("Git fetch failed", "error", err, "output", strings.TrimSpace(string(output)))
+		return false, fmt.Errorf("git fetch failed: %w", err)
+	}
+	commits, err := gitIncomingCommits(ctx, p.Path, rev)
+	if err != nil {
+		log.Error("Could not list incoming commits", "error", err)
+		return false, err
+	}
+	if len(commits) == 0 {
+		return true, nil
+	}
+
+	log.Info("Incoming changes", "commits", len(commits))
+	for _, c := range commits {
+		fmt.Fprintf(out, "  %s\n", c)
+	}
+	if confirm := updaterFrom(ctx).opts.Confirm; confirm != nil && !confirm(fmt.Sprintf("Deploy %d new commit(s) to %s?", len(commits), p.Name)) {
+		log.Info("Update declined, skipping")
+		return false, nil
+	}
+	return true, nil
+}
+
+// PendingCommits fetches the branch or tag p deploys and returns the commits
+// on it that the checkout doesn't have yet, one "<hash> <subject>" line each.
+func PendingCommits(ctx context.Context, p Project) ([]string, error) {
+	rev, output, err := gitFetchUpstream(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s", err, strings.TrimSpace(string(output)))
+	}
+	return gitIncomingCommits(ctx, p.Path, rev)
+}
+
+// gitChangedFiles returns the paths of the files that differ between the
+// commits from and to in the checkout at path. A renamed file is listed
+// under both its old and new name.
+func gitChangedFiles(ctx context.Context, path, from, to string) ([]string, error) {
+	// NUL-terminated names are neither quoted nor split on spaces.
+	out, err := runGit(ctx, "-C", path, "diff", "-z", "--name-only", "--no-renames", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("git diff: %w", err)
+	}
+	files := strings.Split(string(out), "\x00")
+	return files[:len(files)-1], nil
+}
+
+// matchesBuildPaths reports whether any of files matches one of p's
+// BuildPaths. A pattern matches a file or any directory containing it, so
+// "frontend" covers everything below frontend/. A pattern without a slash is
+// matched against the last element only, at any depth, so "*.go" covers Go
+// files in every directory. Files are slash-separated, as git prints them.
+func (p Project) matchesBuildPaths(files []string) bool {
+	for _, file := range files {
+		for dir := file; dir != "."; dir = path.Dir(dir) {
+			for _, pattern := range p.BuildPaths {
+				name := dir
+				if !strings.Contains(pattern, "/") {
+					name = path.Base(dir)
+				}
+				if ok, _ := path.Match(strings.TrimSuffix(pattern, "/"), name); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package updatectl
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGitChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name string) {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("README.md")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	from := git("rev-parse", "HEAD")
+	// Spaces and non-ASCII names are split or quoted by a plain --name-only.
+	write("web app/index.html")
+	write("docs/größe.md")
+	git("add", ".")
+	git("commit", "-q", "-m", "second")
+	to := git("rev-parse", "HEAD")
+
+	files, err := gitChangedFiles(context.Background(), dir, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docs/größe.md", "web app/index.html"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("gitChangedFiles = %q, want %q", files, want)
+	}
+	if p := (Project{BuildPaths: []string{"web app"}}); !p.matchesBuildPaths(files) {
+		t.Errorf("buildPaths %q don't match %q", p.BuildPaths, files)
+	}
+
+	files, err = gitChangedFiles(context.Background(), dir, to, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("gitChangedFiles with no changes = %q, want none", files)
+	}
+}
@@ -0,0 +1,82 @@
+package updatectl
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// behindNotified holds, per project, the remote commit or image digest last
+// announced with a "behind" notification, so each new version is announced
+// once instead of on every check.
+var behindNotified = struct {
+	sync.Mutex
+	versions map[string]string
+}{versions: make(map[string]string)}
+
+// checkProject is called instead of deploying when Options.CheckOnly is set. It
+// fetches what p would deploy, without touching the checkout, logs how far
+// behind the checkout is and sends a "behind" notification the first time a
+// new version shows up.
+func checkProject(ctx context.Context, config Config, p Project, clone bool, log *slog.Logger) error {
+	var latest string
+	var commits int
+	switch {
+	case clone:
+		log.Info("Not cloned yet", "repo", redactedURL(p.Repo), "path", p.Path)
+		return nil
+	case p.Type == "image":
+		current, _ := getImageDigest(ctx, p.Image)
+		remote, err := getRemoteImageDigest(ctx, p.Image)
+		if err != nil || remote == "" {
+			log.Warn("Could not check remote digest", "image", p.Image, "error", err)
+			return nil
+		}
+		if strings.HasSuffix(current, remote) {
+			log.Info("Image up to date", "image", p.Image)
+			announceBehind(p.Name, "")
+			return nil
+		}
+		latest = remote
+		log.Info("New image available", "image", p.Image, "local", orDash(current), "remote", remote)
+	default:
+		if err := gitCheckRemote(ctx, p); err != nil {
+			log.Error("Git remote not found", "error", err)
+			return err
+		}
+		head, remote, err := incomingCommit(ctx, config, p, log)
+		if err != nil {
+			return err
+		}
+		if latest = remote; latest == head {
+			log.Info("Up to date", "commit", head)
+			announceBehind(p.Name, "")
+			return nil
+		}
+		commits = gitCommitCount(ctx, p.Path, head, latest)
+		log.Info("Behind remote", "commits", commits, "commit", head, "remote", latest)
+	}
+
+	if announceBehind(p.Name, latest) {
+		notify(ctx, config.Notify, notifyEvent{Project: p.Name, Event: "behind", Commit: latest, Commits: commits, Success: true}, log)
+	}
+	return nil
+}
+
+// announceBehind records latest as the version available for project and
+// reports whether it hasn't been announced yet. An empty latest means the
+// project is up to date and clears the record.
+func announceBehind(project, latest string) bool {
+	behindNotified.Lock()
+	defer behindNotified.Unlock()
+	if latest == "" {
+		delete(behindNotified.versions, project)
+		return false
+	}
+	if behindNotified.versions[project] == latest {
+		return false
+	}
+	behindNotified.versions[project] = latest
+	return true
+}
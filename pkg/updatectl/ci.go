@@ -0,0 +1,248 @@
+package updatectl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CIStatus makes deploys wait until CI has passed for the commit being
+// deployed.
+type CIStatus struct {
+	Provider   string `yaml:"provider" json:"provider" toml:"provider"`                                     // Only github is supported
+	Token      string `yaml:"token,omitempty" json:"token,omitempty" toml:"token,omitempty"`                // API token; defaults to the project's token, then GITHUB_TOKEN
+	Repository string `yaml:"repository,omitempty" json:"repository,omitempty" toml:"repository,omitempty"` // owner/name; defaults to the one in the project's repo URL
+	APIURL     string `yaml:"apiURL,omitempty" json:"apiURL,omitempty" toml:"apiURL,omitempty"`             // API base URL for GitHub Enterprise (default https://api.github.com)
+}
+
+// ciProviders lists the accepted CIStatus.Provider values.
+var ciProviders = []string{"github"}
+
+const (
+	defaultGitHubAPI = "https://api.github.com"
+	ciTimeout        = 15 * time.Second
+)
+
+var ciClient = &http.Client{
+	Timeout:   ciTimeout,
+	Transport: &http.Transport{Proxy: proxyForRequest},
+}
+
+// The outcomes of checking CI for a commit.
+const (
+	ciSuccess = "success"
+	ciPending = "pending"
+	ciFailure = "failure"
+)
+
+// githubRepoPattern picks owner/name out of an HTTPS, SSH or scp-style
+// GitHub URL.
+var githubRepoPattern = regexp.MustCompile(`^(?:https?://|ssh://)?(?:[^@/]+@)?[^/:]+[:/]([^/]+/[^/]+?)(?:\.git)?/?$`)
+
+// githubRepository returns the owner/name part of the GitHub URL raw.
+func githubRepository(raw string) (string, bool) {
+	m := githubRepoPattern.FindStringSubmatch(raw)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// ciRepository returns the owner/name CI is checked for: the configured
+// repository, or the one in p's repo URL or in the URL of its remote.
+func ciRepository(ctx context.Context, p Project) (string, error) {
+	if p.RequireCIStatus.Repository != "" {
+		return p.RequireCIStatus.Repository, nil
+	}
+	raw := p.Repo
+	if raw == "" {
+		out, err := runGit(ctx, "-C", p.Path, "remote", "get-url", projectRemote(p))
+		if err != nil {
+			return "", fmt.Errorf("could not read the URL of remote %s", projectRemote(p))
+		}
+		raw = strings.TrimSpace(string(out))
+	}
+	repo, ok := githubRepository(raw)
+	if !ok {
+		return "", fmt.Errorf("can't tell the GitHub repository from %s; set requireCIStatus.repository", redactedURL(raw))
+	}
+	return repo, nil
+}
+
+// ciToken returns the token for p's CI API requests, or "" to make them
+// unauthenticated. Without requireCIStatus.token, the project's token is
+// used: a token that clones a GitHub repository over HTTPS can usually read
+// its statuses too. Then GITHUB_TOKEN is, as set in GitHub Actions.
+func ciToken(p Project) string {
+	switch {
+	case p.RequireCIStatus.Token != "":
+		return p.RequireCIStatus.Token
+	case p.Token != "":
+		return p.Token
+	}
+	return os.Getenv("GITHUB_TOKEN")
+}
+
+// githubCIStatus combines the commit statuses and the check runs reported
+// for commit into one outcome: failure if any failed, pending if any is
+// still running or nothing has reported yet, and success otherwise.
+func githubCIStatus(ctx context.Context, p Project, commit string) (string, error) {
+	repo, err := ciRepository(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	api := strings.TrimSuffix(p.RequireCIStatus.APIURL, "/")
+	if api == "" {
+		api = defaultGitHubAPI
+	}
+	base := api + "/repos/" + repo + "/commits/" + url.PathEscape(commit)
+
+	var combined struct {
+		State      string `json:"state"`
+		TotalCount int    `json:"total_count"`
+	}
+	if _, err := githubGet(ctx, p, base+"/status", &combined); err != nil {
+		return "", err
+	}
+	type checkRun struct {
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+	}
+	// A commit can have more check runs than fit on one page.
+	var runs []checkRun
+	for next := base + "/check-runs?per_page=100"; next != ""; {
+		var page struct {
+			CheckRuns []checkRun `json:"check_runs"`
+		}
+		if next, err = githubGet(ctx, p, next, &page); err != nil {
+			return "", err
+		}
+		runs = append(runs, page.CheckRuns...)
+		// The token goes with every page, so only follow links that stay
+		// on the API it was meant for.
+		if next != "" && !sameOrigin(next, api) {
+			return "", fmt.Errorf("check runs: next page %s is not on %s", next, api)
+		}
+	}
+
+	pending := combined.TotalCount == 0 && len(runs) == 0
+	if combined.TotalCount > 0 {
+		switch combined.State {
+		case "failure", "error":
+			return ciFailure, nil
+		case "pending":
+			pending = true
+		}
+	}
+	for _, run := range runs {
+		switch {
+		case run.Status != "completed":
+			pending = true
+		case run.Conclusion == "success" || run.Conclusion == "neutral" || run.Conclusion == "skipped":
+		default:
+			return ciFailure, nil
+		}
+	}
+	if pending {
+		return ciPending, nil
+	}
+	return ciSuccess, nil
+}
+
+// githubGet decodes the JSON response to a GitHub API GET request into v,
+// and returns the URL of the next page of results, if there is one. The
+// token is only ever sent in the Authorization header.
+func githubGet(ctx context.Context, p Project, url string, v any) (next string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := ciToken(p); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := ciClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", req.URL.Path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+// nextPageURL returns the rel="next" URL of a Link header, as in
+// `<https://api.github.com/...&page=2>; rel="next", <...>; rel="last"`.
+func nextPageURL(link string) string {
+	for part := range strings.SplitSeq(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok {
+			continue
+		}
+		for param := range strings.SplitSeq(params, ";") {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+// sameOrigin reports whether the URLs a and b have the same scheme and host.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// ciAllows reports whether CI has passed for commit. A pending or failed CI
+// is logged and defers the deploy; an error means CI couldn't be asked.
+func ciAllows(ctx context.Context, p Project, commit string, log *slog.Logger) (bool, error) {
+	status, err := githubCIStatus(ctx, p, commit)
+	if err != nil {
+		log.Error("Could not read CI status", "commit", commit, "error", err)
+		return false, fmt.Errorf("reading CI status: %w", err)
+	}
+	switch status {
+	case ciSuccess:
+		log.Info("CI passed", "commit", commit)
+		return true, nil
+	case ciPending:
+		log.Info("CI hasn't finished, deploy deferred", "commit", commit)
+	default:
+		log.Warn("CI failed, deploy deferred", "commit", commit)
+	}
+	return false, nil
+}
+
+// checkIncomingCI fetches the commit p would deploy and checks CI for it
+// before the checkout is touched. It returns the commit checked, or HEAD if
+// nothing new arrived, and whether the update may go ahead.
+func checkIncomingCI(ctx context.Context, config Config, p Project, log *slog.Logger) (string, bool, error) {
+	head, commit, err := incomingCommit(ctx, config, p, log)
+	if err != nil {
+		return "", false, err
+	}
+	if commit == head {
+		return commit, true, nil
+	}
+	ok, err := ciAllows(ctx, p, commit, log)
+	return commit, ok, err
+}
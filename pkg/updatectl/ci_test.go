@@ -0,0 +1,89 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitHubCIStatusPages(t *testing.T) {
+	// The failed check run is on the second page of check runs.
+	pages := []string{
+		`{"total_count": 2, "check_runs": [{"status": "completed", "conclusion": "success"}]}`,
+		`{"total_count": 2, "check_runs": [{"status": "completed", "conclusion": "failure"}]}`,
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/app/commits/abc123/status":
+			fmt.Fprint(w, `{"state": "pending", "total_count": 0}`)
+		case "/repos/acme/app/commits/abc123/check-runs":
+			page := 0
+			if r.URL.Query().Get("page") == "2" {
+				page = 1
+			} else {
+				w.Header().Set("Link", fmt.Sprintf(`<%s%s?per_page=100&page=2>; rel="next", <%[1]s%[2]s?per_page=100&page=2>; rel="last"`, srv.URL, r.URL.Path))
+			}
+			fmt.Fprint(w, pages[page])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := Project{Name: "app", RequireCIStatus: &CIStatus{Provider: "github", Repository: "acme/app", APIURL: srv.URL}}
+	status, err := githubCIStatus(context.Background(), p, "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != ciFailure {
+		t.Errorf("status = %q, want %q", status, ciFailure)
+	}
+}
+
+func TestGitHubCIStatusForeignNextPage(t *testing.T) {
+	// A next page elsewhere must not receive the token.
+	var leaked bool
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("Authorization") != ""
+		fmt.Fprint(w, `{"total_count": 0, "check_runs": []}`)
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/app/commits/abc123/status":
+			fmt.Fprint(w, `{"state": "pending", "total_count": 0}`)
+		case "/repos/acme/app/commits/abc123/check-runs":
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=2>; rel="next"`, other.URL, r.URL.Path))
+			fmt.Fprint(w, `{"total_count": 2, "check_runs": [{"status": "completed", "conclusion": "success"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := Project{Name: "app", Token: "secret", RequireCIStatus: &CIStatus{Provider: "github", Repository: "acme/app", APIURL: srv.URL}}
+	if _, err := githubCIStatus(context.Background(), p, "abc123"); err == nil {
+		t.Error("githubCIStatus followed a next page on another host")
+	}
+	if leaked {
+		t.Error("token sent to another host")
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		link, want string
+	}{
+		{"", ""},
+		{`<https://api.github.com/x?page=2>; rel="next", <https://api.github.com/x?page=5>; rel="last"`, "https://api.github.com/x?page=2"},
+		{`<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=1>; rel="first"`, ""},
+	}
+	for _, tt := range tests {
+		if got := nextPageURL(tt.link); got != tt.want {
+			t.Errorf("nextPageURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package updatectl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// runCompose runs docker compose with args for p in p's path, with its
+// ComposeFile if one is set, streaming its output to out and including its
+// stderr in the returned error.
+func runCompose(ctx context.Context, p Project, out io.Writer, args ...string) error {
+	composeArgs := []string{"compose"}
+	if p.ComposeFile != "" {
+		composeArgs = append(composeArgs, "-f", p.ComposeFile)
+	}
+	var stderr bytes.Buffer
+	cmd := Command{Name: "docker", Args: append(composeArgs, args...), Dir: p.Path, Stdout: out, Stderr: io.MultiWriter(out, &stderr)}
+	if _, err := runCommand(ctx, cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("docker compose %s: %w: %s", args[0], err, msg)
+		}
+		return fmt.Errorf("docker compose %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// deployCompose pulls the images of p's compose services and recreates any
+// whose image or configuration changed.
+func deployCompose(ctx context.Context, p Project, log *slog.Logger, out io.Writer) error {
+	log.Info("Pulling Docker Compose images", "path", p.Path, "file", p.ComposeFile)
+	if err := runCompose(ctx, p, out, "pull"); err != nil {
+		return err
+	}
+	log.Info("Starting Docker Compose services", "path", p.Path)
+	return runCompose(ctx, p, out, "up", "-d")
+}
+
+// pruneDanglingImages runs docker image prune to remove the untagged images
+// left behind by rebuilds, logging how many were deleted and the space
+// reclaimed. Failures are only logged, as the deploy itself succeeded.
+func pruneDanglingImages(ctx context.Context, log *slog.Logger) {
+	output, err := withStderr(runCommand(ctx, Command{Name: "docker", Args: []string{"image", "prune", "-f"}}))
+	if err != nil {
+		log.Warn("Failed to prune dangling images", "error", err, "output", strings.TrimSpace(string(output)))
+		return
+	}
+	deleted, reclaimed := 0, "0B"
+	for line := range strings.SplitSeq(string(output), "\n") {
+		if strings.HasPrefix(line, "deleted: ") {
+			deleted++
+		} else if size, ok := strings.CutPrefix(line, "Total reclaimed space:"); ok {
+			reclaimed = strings.TrimSpace(size)
+		}
+	}
+	log.Info("Pruned dangling images", "deleted", deleted, "reclaimed", reclaimed)
+}
+
+// deployProject brings the freshly built version of p into service. Unlike
+// RestartProject it is only used as part of an update.
+func deployProject(ctx context.Context, p Project, log *slog.Logger, out io.Writer) error {
+	switch p.Type {
+	case "docker":
+		// Docker projects are brought up by their build command, so they
+		// only need an explicit restart when triggered by hand.
+		return nil
+	case "docker-compose":
+		return deployCompose(ctx, p, log, out)
+	}
+	return updaterFrom(ctx).RestartProject(ctx, p, log, out)
+}
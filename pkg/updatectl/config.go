@@ -0,0 +1,682 @@
+package updatectl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// IsRunningInDocker reports whether updatectl is running inside a container,
+// where projects are discovered from the Docker socket instead of a config file.
+func IsRunningInDocker() bool {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return true
+	}
+	data, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), "docker") || strings.Contains(string(data), "containerd")
+}
+
+func discoverProjectsFromContainers(ctx context.Context) []Project {
+	output, err := runCommand(ctx, Command{Name: "docker", Args: []string{"ps", "--format", "{{.Names}}"}})
+	if err != nil {
+		Logger.Error("Failed to list containers", "error", err)
+		return nil
+	}
+
+	var projects []Project
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+
+	Logger.Info("Discovering containers", "running", len(lines))
+
+	for _, line := range lines {
+		name := strings.TrimSpace(line)
+		if name == "" {
+			continue
+		}
+
+		if strings.Contains(name, "updatectl") {
+			Logger.Debug("Skipping updatectl container", "container", name)
+			continue
+		}
+
+		// Get the actual image name from inspect (handles image IDs)
+		imageOutput, err := runCommand(ctx, Command{Name: "docker", Args: []string{"inspect", "--format", "{{.Config.Image}}", name}})
+		if err != nil {
+			Logger.Warn("Failed to inspect container", "container", name, "error", err)
+			continue
+		}
+		image := strings.TrimSpace(string(imageOutput))
+
+		// Filter for docker.io or ghcr.io images, but also allow images without prefix
+		// Docker Hub images often don't have docker.io/ prefix
+		hasValidPrefix := strings.HasPrefix(image, "docker.io/") ||
+			strings.HasPrefix(image, "ghcr.io/")
+
+		// Also check if it looks like a registry image (contains / or :)
+		looksLikeRegistryImage := strings.Contains(image, "/") || strings.Contains(image, ":")
+
+		if !hasValidPrefix && !looksLikeRegistryImage {
+			Logger.Debug("Skipping local image", "container", name, "image", image)
+			continue
+		}
+
+		ports := getContainerPublishedPorts(ctx, name)
+		env := getContainerEnv(ctx, name)
+
+		project := Project{
+			Name:  name,
+			Type:  "image",
+			Image: image,
+			Port:  ports,
+			Env:   env,
+		}
+		projects = append(projects, project)
+		Logger.Info("Discovered container", "container", name, "image", image, "ports", ports, "envVars", len(env))
+	}
+
+	Logger.Info("Total containers to monitor", "count", len(projects))
+	return projects
+}
+
+func getContainerPublishedPorts(ctx context.Context, containerName string) string {
+	// Get the port bindings in a more reliable format
+	output, err := runCommand(ctx, Command{Name: "docker", Args: []string{"port", containerName}})
+	if err != nil {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	portMap := make(map[string]bool) // Use map to deduplicate
+	var portMappings []string
+
+	for _, line := range lines {
+		// Format is like: "80/tcp -> 0.0.0.0:8081" or "80/tcp -> [::]:8081"
+		if strings.Contains(line, "->") {
+			parts := strings.Split(line, "->")
+			if len(parts) != 2 {
+				continue
+			}
+
+			// Get container port (left side, e.g., "80/tcp")
+			containerPort := strings.TrimSpace(parts[0])
+			containerPort = strings.TrimSuffix(containerPort, "/tcp")
+			containerPort = strings.TrimSuffix(containerPort, "/udp")
+
+			// Get host binding (right side, e.g., "0.0.0.0:8081" or "[::]:8081")
+			hostBinding := strings.TrimSpace(parts[1])
+
+			hostParts := strings.Split(hostBinding, ":")
+			if len(hostParts) >= 2 {
+				hostPort := hostParts[len(hostParts)-1]
+				portMapping := fmt.Sprintf("%s:%s", hostPort, containerPort)
+
+				// Only add if we haven't seen this mapping before
+				if !portMap[portMapping] {
+					portMap[portMapping] = true
+					portMappings = append(portMappings, portMapping)
+				}
+			}
+		}
+	}
+
+	return strings.Join(portMappings, " ")
+}
+
+func getContainerEnv(ctx context.Context, containerName string) map[string]string {
+	output, err := runCommand(ctx, Command{Name: "docker", Args: []string{"inspect", "--format", `{{range .Config.Env}}{{println .}}{{end}}`, containerName}})
+	if err != nil {
+		return nil
+	}
+
+	env := make(map[string]string)
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+			// Skip PATH and other system env vars that might cause issues
+			key := kv[0]
+			if key != "PATH" && key != "HOSTNAME" {
+				env[key] = kv[1]
+			}
+		}
+	}
+
+	return env
+}
+
+// Project is one deployable application in the config.
+type Project struct {
+	Name                string            `yaml:"name" json:"name" toml:"name"`
+	Path                string            `yaml:"path,omitempty" json:"path,omitempty" toml:"path,omitempty"`
+	Repo                string            `yaml:"repo,omitempty" json:"repo,omitempty" toml:"repo,omitempty"`
+	Type                string            `yaml:"type,omitempty" json:"type,omitempty" toml:"type,omitempty"`
+	BuildCommand        Commands          `yaml:"buildCommand,omitempty" json:"buildCommand,omitempty" toml:"buildCommand,omitempty"`
+	BuildDir            string            `yaml:"buildDir,omitempty" json:"buildDir,omitempty" toml:"buildDir,omitempty"`                                 // Optional directory the build command runs in (relative to path)
+	BuildPaths          []string          `yaml:"buildPaths,omitempty" json:"buildPaths,omitempty" toml:"buildPaths,omitempty"`                           // Optional globs; new commits that change no matching file are checked out without building or restarting
+	Image               string            `yaml:"image,omitempty" json:"image,omitempty" toml:"image,omitempty"`                                          // Docker image to pull (e.g., "ghcr.io/user/vite-app:main")
+	Port                string            `yaml:"port,omitempty" json:"port,omitempty" toml:"port,omitempty"`                                             // Port mapping (e.g., "80:80" or "3000:80")
+	Env                 map[string]string `yaml:"env,omitempty" json:"env,omitempty" toml:"env,omitempty"`                                                // Environment variables for build commands, hooks and image containers
+	EnvFile             string            `yaml:"envFile,omitempty" json:"envFile,omitempty" toml:"envFile,omitempty"`                                    // Optional dotenv file merged under Env (relative to path)
+	ContainerName       string            `yaml:"containerName,omitempty" json:"containerName,omitempty" toml:"containerName,omitempty"`                  // Optional custom container name
+	Interval            int               `yaml:"interval,omitempty" json:"interval,omitempty" toml:"interval,omitzero"`                                  // Optional per-project interval in seconds (overrides global)
+	SSHKey              string            `yaml:"sshKey,omitempty" json:"sshKey,omitempty" toml:"sshKey,omitempty"`                                       // Optional SSH private key used for git operations
+	Token               string            `yaml:"token,omitempty" json:"token,omitempty" toml:"token,omitempty"`                                          // Optional access token for HTTPS repos (ignored if sshKey is set)
+	ServiceName         string            `yaml:"serviceName,omitempty" json:"serviceName,omitempty" toml:"serviceName,omitempty"`                        // Optional systemd unit for systemd type (defaults to project name)
+	Namespace           string            `yaml:"namespace,omitempty" json:"namespace,omitempty" toml:"namespace,omitempty"`                              // Optional Kubernetes namespace for kubernetes type
+	Deployment          string            `yaml:"deployment,omitempty" json:"deployment,omitempty" toml:"deployment,omitempty"`                           // Kubernetes deployment to restart (defaults to project name)
+	Manifest            string            `yaml:"manifest,omitempty" json:"manifest,omitempty" toml:"manifest,omitempty"`                                 // Optional file or directory passed to kubectl apply -f (relative to path)
+	ComposeFile         string            `yaml:"composeFile,omitempty" json:"composeFile,omitempty" toml:"composeFile,omitempty"`                        // Optional compose file for docker-compose type (relative to path)
+	Branch              string            `yaml:"branch,omitempty" json:"branch,omitempty" toml:"branch,omitempty"`                                       // Optional branch to deploy; resets the checkout to <remote>/<branch>
+	TrackTags           *bool             `yaml:"trackTags,omitempty" json:"trackTags,omitempty" toml:"trackTags,omitempty"`                              // Deploy the highest semver tag instead of a branch
+	Ref                 string            `yaml:"ref,omitempty" json:"ref,omitempty" toml:"ref,omitempty"`                                                // Optional commit, tag or branch to stay on until the config changes
+	TagPattern          string            `yaml:"tagPattern,omitempty" json:"tagPattern,omitempty" toml:"tagPattern,omitempty"`                           // Optional glob the tags deployed by trackTags must match (e.g. "v*")
+	BuildTimeoutSeconds int               `yaml:"buildTimeoutSeconds,omitempty" json:"buildTimeoutSeconds,omitempty" toml:"buildTimeoutSeconds,omitzero"` // Optional build timeout (overrides global)
+	PreUpdate           string            `yaml:"preUpdate,omitempty" json:"preUpdate,omitempty" toml:"preUpdate,omitempty"`                              // Optional command run after new commits arrive, before the build
+	PostUpdate          string            `yaml:"postUpdate,omitempty" json:"postUpdate,omitempty" toml:"postUpdate,omitempty"`                           // Optional command run after a successful restart
+	AutoRollback        *bool             `yaml:"autoRollback,omitempty" json:"autoRollback,omitempty" toml:"autoRollback,omitempty"`                     // Reset to the previous commit if the build or restart fails
+	HealthCheck         *HealthCheck      `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty" toml:"healthCheck,omitempty"`                        // Optional HTTP check that must pass after restart
+	RequireCIStatus     *CIStatus         `yaml:"requireCIStatus,omitempty" json:"requireCIStatus,omitempty" toml:"requireCIStatus,omitempty"`            // Optional: only deploy commits whose CI has passed
+	Retries             int               `yaml:"retries,omitempty" json:"retries,omitempty" toml:"retries,omitzero"`                                     // Optional retries for transient git failures (overrides global)
+	RetryBackoffSeconds int               `yaml:"retryBackoffSeconds,omitempty" json:"retryBackoffSeconds,omitempty" toml:"retryBackoffSeconds,omitzero"` // Optional initial retry delay (overrides global)
+	Depth               int               `yaml:"depth,omitempty" json:"depth,omitempty" toml:"depth,omitzero"`                                           // Optional history depth; clones and fetches only the last N commits
+	SparsePaths         []string          `yaml:"sparsePaths,omitempty" json:"sparsePaths,omitempty" toml:"sparsePaths,omitempty"`                        // Optional directories to check out, leaving the rest of a monorepo out (git sparse-checkout)
+	Remote              string            `yaml:"remote,omitempty" json:"remote,omitempty" toml:"remote,omitempty"`                                       // Optional git remote to deploy from (default origin, or the upstream's remote)
+	URLRewrites         map[string]string `yaml:"urlRewrites,omitempty" json:"urlRewrites,omitempty" toml:"urlRewrites,omitempty"`                        // Optional URL prefix rewrites for git, e.g. a mirror (git's insteadOf)
+	Schedule            *Schedule         `yaml:"schedule,omitempty" json:"schedule,omitempty" toml:"schedule,omitempty"`                                 // Optional maintenance window (overrides global)
+	Cron                string            `yaml:"cron,omitempty" json:"cron,omitempty" toml:"cron,omitempty"`                                             // Optional cron expression for checks (overrides interval)
+	WebhookSecret       string            `yaml:"webhookSecret,omitempty" json:"webhookSecret,omitempty" toml:"webhookSecret,omitempty"`                  // Optional secret that enables /hooks/<name> and verifies its signatures
+	OnDirty             string            `yaml:"onDirty,omitempty" json:"onDirty,omitempty" toml:"onDirty,omitempty"`                                    // What to do with local changes before updating: skip, stash or reset (default skip)
+	PullStrategy        string            `yaml:"pullStrategy,omitempty" json:"pullStrategy,omitempty" toml:"pullStrategy,omitempty"`                     // How the checkout follows its upstream: ff-only, rebase or reset (default ff-only)
+	Submodules          *bool             `yaml:"submodules,omitempty" json:"submodules,omitempty" toml:"submodules,omitempty"`                           // Update git submodules after pulling, before the build
+	PruneImages         *bool             `yaml:"pruneImages,omitempty" json:"pruneImages,omitempty" toml:"pruneImages,omitempty"`                        // Run docker image prune after a successful deploy (docker and docker-compose types)
+	VerifySignature     *bool             `yaml:"verifySignature,omitempty" json:"verifySignature,omitempty" toml:"verifySignature,omitempty"`            // Only deploy commits with a valid, trusted signature
+	AllowedSigners      string            `yaml:"allowedSigners,omitempty" json:"allowedSigners,omitempty" toml:"allowedSigners,omitempty"`               // Optional allowed signers file for SSH signatures (git's gpg.ssh.allowedSignersFile)
+	RunAsUser           string            `yaml:"runAsUser,omitempty" json:"runAsUser,omitempty" toml:"runAsUser,omitempty"`                              // Optional user the build, hooks and pm2 restart run as (Unix only)
+	DeployPath          string            `yaml:"deployPath,omitempty" json:"deployPath,omitempty" toml:"deployPath,omitempty"`                           // Optional web root a static project is published to, as a symlink to the live release
+	OutputDir           string            `yaml:"outputDir,omitempty" json:"outputDir,omitempty" toml:"outputDir,omitempty"`                              // Optional directory published to deployPath (relative to path; default the whole checkout)
+	Rsync               *bool             `yaml:"rsync,omitempty" json:"rsync,omitempty" toml:"rsync,omitempty"`                                          // Publish with rsync, hard-linking unchanged files, when it is installed
+}
+
+// The switches below can be turned on in defaults and off again in a
+// project, so a setting left out is told apart from one set to false.
+
+// TracksTags reports whether p deploys its highest semver tag.
+func (p Project) TracksTags() bool { return p.TrackTags != nil && *p.TrackTags }
+
+// RollsBack reports whether p is reset to its previous commit when a build
+// or restart fails.
+func (p Project) RollsBack() bool { return p.AutoRollback != nil && *p.AutoRollback }
+
+// UpdatesSubmodules reports whether p's submodules are updated after a pull.
+func (p Project) UpdatesSubmodules() bool { return p.Submodules != nil && *p.Submodules }
+
+// PrunesImages reports whether unused docker images are pruned after p is
+// deployed.
+func (p Project) PrunesImages() bool { return p.PruneImages != nil && *p.PruneImages }
+
+// VerifiesSignatures reports whether p only deploys signed commits.
+func (p Project) VerifiesSignatures() bool { return p.VerifySignature != nil && *p.VerifySignature }
+
+// UsesRsync reports whether p is published with rsync.
+func (p Project) UsesRsync() bool { return p.Rsync != nil && *p.Rsync }
+
+// BuildPath returns the directory p's build command runs in: BuildDir,
+// resolved relative to Path, or Path itself.
+func (p Project) BuildPath() string {
+	switch {
+	case p.BuildDir == "":
+		return p.Path
+	case filepath.IsAbs(p.BuildDir):
+		return p.BuildDir
+	}
+	return filepath.Join(p.Path, p.BuildDir)
+}
+
+// Commands is a list of commands run one after another, such as the steps
+// of a build. In the config it is either a single command string or a list.
+type Commands []string
+
+// UnmarshalYAML accepts a single command or a list of commands.
+func (c *Commands) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = nil
+		if node.Value != "" {
+			*c = Commands{node.Value}
+		}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return fmt.Errorf("line %d: expected a command or a list of commands", node.Line)
+	}
+	*c = list
+	return nil
+}
+
+// MarshalYAML writes a single command as a plain string, so configs that
+// never used a list keep their shape.
+func (c Commands) MarshalYAML() (any, error) {
+	if len(c) == 1 {
+		return c[0], nil
+	}
+	return []string(c), nil
+}
+
+// UnmarshalJSON accepts a single command or a list of commands.
+func (c *Commands) UnmarshalJSON(data []byte) error {
+	var command string
+	if err := json.Unmarshal(data, &command); err == nil {
+		*c = nil
+		if command != "" {
+			*c = Commands{command}
+		}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("expected a command or a list of commands")
+	}
+	*c = list
+	return nil
+}
+
+// MarshalJSON writes a single command as a plain string, like MarshalYAML.
+func (c Commands) MarshalJSON() ([]byte, error) {
+	if len(c) == 1 {
+		return json.Marshal(c[0])
+	}
+	return json.Marshal([]string(c))
+}
+
+// UnmarshalTOML accepts a single command or a list of commands.
+func (c *Commands) UnmarshalTOML(data any) error {
+	switch v := data.(type) {
+	case string:
+		*c = nil
+		if v != "" {
+			*c = Commands{v}
+		}
+		return nil
+	case []any:
+		list := make(Commands, len(v))
+		for i, item := range v {
+			command, ok := item.(string)
+			if !ok {
+				return errors.New("expected a command or a list of commands")
+			}
+			list[i] = command
+		}
+		*c = list
+		return nil
+	}
+	return errors.New("expected a command or a list of commands")
+}
+
+// MarshalTOML writes a single command as a plain string, like MarshalYAML.
+func (c Commands) MarshalTOML() ([]byte, error) {
+	// A JSON string is also a TOML basic string.
+	quoted := make([]string, len(c))
+	for i, command := range c {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(command); err != nil {
+			return nil, err
+		}
+		quoted[i] = strings.TrimSpace(buf.String())
+	}
+	if len(c) == 1 {
+		return []byte(quoted[0]), nil
+	}
+	return []byte("[" + strings.Join(quoted, ", ") + "]"), nil
+}
+
+// String joins the commands with " && ", the shell equivalent of running
+// them in order until one fails.
+func (c Commands) String() string {
+	return strings.Join(c, " && ")
+}
+
+// Config is the parsed updatectl.yaml.
+type Config struct {
+	// Deprecated: Use Interval instead.
+	IntervalMinutes       int            `yaml:"intervalMinutes,omitempty" json:"intervalMinutes,omitempty" toml:"intervalMinutes,omitzero"`
+	Interval              int            `yaml:"interval,omitempty" json:"interval,omitempty" toml:"interval,omitzero"`
+	Cron                  string         `yaml:"cron,omitempty" json:"cron,omitempty" toml:"cron,omitempty"`                                                   // Cron expression for checks, used instead of interval
+	IntervalJitterSeconds int            `yaml:"intervalJitterSeconds,omitempty" json:"intervalJitterSeconds,omitempty" toml:"intervalJitterSeconds,omitzero"` // Random 0..N seconds added to each interval and to the first check (default 0)
+	RunOnStart            *bool          `yaml:"runOnStart,omitempty" json:"runOnStart,omitempty" toml:"runOnStart,omitempty"`                                 // Check interval projects as soon as watch starts instead of after one interval (default true)
+	Concurrency           int            `yaml:"concurrency,omitempty" json:"concurrency,omitempty" toml:"concurrency,omitzero"`                               // Max projects updated in parallel (defaults to number of CPUs)
+	GitConcurrency        int            `yaml:"gitConcurrency,omitempty" json:"gitConcurrency,omitempty" toml:"gitConcurrency,omitzero"`                      // Max git clones, fetches and pulls running at once, across all projects (default 4)
+	BuildTimeoutSeconds   int            `yaml:"buildTimeoutSeconds,omitempty" json:"buildTimeoutSeconds,omitempty" toml:"buildTimeoutSeconds,omitzero"`       // Max build duration (default 600)
+	BuildLogs             int            `yaml:"buildLogs,omitempty" json:"buildLogs,omitempty" toml:"buildLogs,omitzero"`                                     // Build logs kept per project (default 10)
+	Retries               int            `yaml:"retries,omitempty" json:"retries,omitempty" toml:"retries,omitzero"`                                           // Retries for transient git failures (default 0)
+	RetryBackoffSeconds   int            `yaml:"retryBackoffSeconds,omitempty" json:"retryBackoffSeconds,omitempty" toml:"retryBackoffSeconds,omitzero"`       // Delay before the first retry, doubled each time (default 5)
+	Shell                 string         `yaml:"shell,omitempty" json:"shell,omitempty" toml:"shell,omitempty"`                                                // Shell for build commands and hooks (default bash, cmd on Windows; "none" for no shell)
+	Webhook               *WebhookConfig `yaml:"webhook,omitempty" json:"webhook,omitempty" toml:"webhook,omitempty"`                                          // Serve push webhooks that trigger immediate updates (default off)
+	MetricsAddr           string         `yaml:"metricsAddr,omitempty" json:"metricsAddr,omitempty" toml:"metricsAddr,omitempty"`                              // Address the watch daemon serves Prometheus metrics on, e.g. ":9090" (default off)
+	API                   *APIConfig     `yaml:"api,omitempty" json:"api,omitempty" toml:"api,omitempty"`                                                      // Serve the HTTP control API from the watch daemon (default off)
+	ShowChanges           bool           `yaml:"showChanges,omitempty" json:"showChanges,omitempty" toml:"showChanges,omitempty"`                              // Log the incoming commits before each update
+	Schedule              *Schedule      `yaml:"schedule,omitempty" json:"schedule,omitempty" toml:"schedule,omitempty"`                                       // Maintenance window outside which deploys are deferred (default: always)
+	Notify                NotifyConfig   `yaml:"notify,omitempty" json:"notify,omitempty" toml:"notify,omitempty"`
+	Proxy                 string         `yaml:"proxy,omitempty" json:"proxy,omitempty" toml:"proxy,omitempty"`                   // HTTP(S) proxy for git and notifications, overriding HTTP_PROXY and HTTPS_PROXY
+	ProjectsDir           string         `yaml:"projectsDir,omitempty" json:"projectsDir,omitempty" toml:"projectsDir,omitempty"` // Directory whose git checkouts are added as projects, unless listed in projects
+	Defaults              *Project       `yaml:"defaults,omitempty" json:"defaults,omitempty" toml:"defaults,omitempty"`          // Settings applied to every project that doesn't set them itself
+	Projects              []Project      `yaml:"projects" json:"projects" toml:"projects"`
+}
+
+// IntervalSeconds returns the global check interval, preferring Interval
+// over the deprecated IntervalMinutes.
+func (c Config) IntervalSeconds() int {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return c.IntervalMinutes * 60
+}
+
+// concurrency returns the size of the update worker pool.
+func (c Config) concurrency() int {
+	if c.Concurrency > 0 {
+		return c.Concurrency
+	}
+	return runtime.NumCPU()
+}
+
+// defaultGitConcurrency caps simultaneous git network operations when
+// gitConcurrency isn't set.
+const defaultGitConcurrency = 4
+
+// gitConcurrency returns how many git network operations may run at once.
+func (c Config) gitConcurrency() int {
+	if c.GitConcurrency > 0 {
+		return c.GitConcurrency
+	}
+	return defaultGitConcurrency
+}
+
+// processWaitDelay is how long to wait for output pipes to close after a
+// cancelled command has been killed.
+const processWaitDelay = 5 * time.Second
+
+// defaultBuildTimeout bounds builds when no timeout is configured.
+const defaultBuildTimeout = 600 * time.Second
+
+// buildTimeout returns how long p's build may run, preferring the project's
+// own setting over the global one.
+func (c Config) buildTimeout(p Project) time.Duration {
+	if p.BuildTimeoutSeconds > 0 {
+		return time.Duration(p.BuildTimeoutSeconds) * time.Second
+	}
+	if c.BuildTimeoutSeconds > 0 {
+		return time.Duration(c.BuildTimeoutSeconds) * time.Second
+	}
+	return defaultBuildTimeout
+}
+
+// defaultRetryBackoff is the delay before the first git retry when none is
+// configured.
+const defaultRetryBackoff = 5 * time.Second
+
+// retries returns how many times a transient git failure for p is retried.
+func (c Config) retries(p Project) int {
+	if p.Retries > 0 {
+		return p.Retries
+	}
+	return c.Retries
+}
+
+// retryBackoff returns the delay before p's first git retry; each further
+// retry waits twice as long as the previous one.
+func (c Config) retryBackoff(p Project) time.Duration {
+	if p.RetryBackoffSeconds > 0 {
+		return time.Duration(p.RetryBackoffSeconds) * time.Second
+	}
+	if c.RetryBackoffSeconds > 0 {
+		return time.Duration(c.RetryBackoffSeconds) * time.Second
+	}
+	return defaultRetryBackoff
+}
+
+// ProjectInterval returns how often p should be checked, falling back to the
+// global interval when the project does not set its own.
+func (c Config) ProjectInterval(p Project) time.Duration {
+	if p.Interval > 0 {
+		return time.Duration(p.Interval) * time.Second
+	}
+	return time.Duration(c.IntervalSeconds()) * time.Second
+}
+
+// RunsOnStart reports whether watch checks interval projects as soon as it
+// starts, which is the default, rather than after their first interval.
+func (c Config) RunsOnStart() bool {
+	return c.RunOnStart == nil || *c.RunOnStart
+}
+
+// Jitter returns a random delay between zero and the configured interval
+// jitter, so hosts sharing an interval don't all check at once.
+func (c Config) Jitter() time.Duration {
+	if c.IntervalJitterSeconds <= 0 {
+		return 0
+	}
+	return rand.N(time.Duration(c.IntervalJitterSeconds)*time.Second + 1)
+}
+
+// DefaultConfigPath returns the platform default location of the config file:
+// updatectl.yaml, or updatectl.json or updatectl.toml if only that exists. On
+// Linux, where the default is in /etc/updatectl, a config installed for the
+// current user at UserConfigPath is used if there is none in /etc.
+func DefaultConfigPath() string {
+	dir := "/etc/updatectl"
+	switch runtime.GOOS {
+	case "windows":
+		dir = filepath.Join(os.Getenv("USERPROFILE"), "updatectl")
+	case "darwin":
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, "Library", "Application Support", "updatectl")
+	}
+	path, found := configInDir(dir)
+	if !found && dir == "/etc/updatectl" {
+		if userPath, ok := configInDir(filepath.Dir(UserConfigPath())); ok {
+			return userPath
+		}
+	}
+	return path
+}
+
+// UserConfigPath returns where init puts the config of a user-level install
+// on Linux: updatectl/updatectl.yaml in the user's config directory, usually
+// ~/.config.
+func UserConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "updatectl", "updatectl.yaml")
+}
+
+// configInDir returns the config file in dir, updatectl.yaml or else
+// updatectl.json or updatectl.toml, and whether any exists.
+func configInDir(dir string) (string, bool) {
+	for _, name := range []string{"updatectl.yaml", "updatectl.json", "updatectl.toml"} {
+		if path := filepath.Join(dir, name); fileExists(path) {
+			return path, true
+		}
+	}
+	return filepath.Join(dir, "updatectl.yaml"), false
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ConfigFormat returns the format of the config file at path from its
+// extension: "json" for .json, "toml" for .toml, otherwise "yaml".
+func ConfigFormat(path string) string {
+	switch ext := filepath.Ext(path); {
+	case strings.EqualFold(ext, ".json"):
+		return "json"
+	case strings.EqualFold(ext, ".toml"):
+		return "toml"
+	}
+	return "yaml"
+}
+
+// ConfigPath is the config file to use. The updatectl command sets it from
+// --config. The directory it is in also holds the state file, locks and build
+// logs, unless it isn't writable (see StateDir).
+var ConfigPath string
+
+// ResolveConfigPath returns ConfigPath, falling back to $UPDATECTL_CONFIG and
+// then the platform default.
+func ResolveConfigPath() string {
+	if ConfigPath != "" {
+		return ConfigPath
+	}
+	if envPath := os.Getenv("UPDATECTL_CONFIG"); envPath != "" {
+		return envPath
+	}
+	return DefaultConfigPath()
+}
+
+// LoadConfig reads and validates the config file at path, adding the
+// projects found in its projectsDir, applying its defaults and resolving its
+// secret references. Inside a Docker container the config is built from the
+// running containers instead.
+func LoadConfig(path string) (Config, error) {
+	if IsRunningInDocker() {
+		return loadConfigFromEnv(), nil
+	}
+
+	c, err := ReadConfigFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	c.addDiscoveredProjects()
+	if err := c.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s:\n%w", path, err)
+	}
+	// Validate has reported any reference that can't be resolved.
+	c, _ = c.withSecrets()
+	setProxy(c.Proxy)
+	return c, nil
+}
+
+// ReadConfigFile parses the config file at path without validating it.
+func ReadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	c, err := DecodeConfig(path, data)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// DecodeConfig parses config file contents in the format of path, as
+// returned by ConfigFormat.
+func DecodeConfig(path string, data []byte) (Config, error) {
+	var c Config
+	switch ConfigFormat(path) {
+	case "json":
+		if err := json.Unmarshal(data, &c); err != nil {
+			return c, jsonErrorWithLine(data, err)
+		}
+		return c, nil
+	case "toml":
+		// toml errors already carry the line ("toml: line 4 (last key ...)")
+		if _, err := toml.Decode(string(data), &c); err != nil {
+			return c, err
+		}
+		return c, nil
+	}
+	// yaml.v3 errors already carry the offending line ("yaml: line 4: ...")
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// jsonErrorWithLine adds the line number to JSON syntax and type errors,
+// which only report a byte offset.
+func jsonErrorWithLine(data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	line := 1 + bytes.Count(data[:min(int(offset), len(data))], []byte("\n"))
+	return fmt.Errorf("json: line %d: %w", line, err)
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// into place, so a crash mid-write never leaves a truncated file behind.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once the rename has succeeded
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func loadConfigFromEnv() Config {
+	config := Config{}
+
+	if intervalStr := os.Getenv("UPDATECTL_INTERVAL"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil {
+			config.Interval = interval
+		}
+	} else {
+		config.Interval = 600 // default 10 minutes
+	}
+
+	// Auto-discover projects from running containers
+	config.Projects = discoverProjectsFromContainers(context.Background())
+
+	return config
+}
@@ -0,0 +1,97 @@
+package updatectl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"updatectl.yaml": `interval: 600
+runOnStart: false
+projects:
+  - name: site
+    path: /srv/site
+    type: static
+    buildCommand: npm ci && npm run build
+    env:
+      NODE_ENV: production
+    healthCheck:
+      url: http://127.0.0.1:8080/health
+      timeoutSeconds: 5
+  - name: api
+    path: /srv/api
+    type: docker
+    buildCommand:
+      - make
+      - make test
+`,
+		"updatectl.json": `{
+  "interval": 600,
+  "runOnStart": false,
+  "projects": [
+    {
+      "name": "site",
+      "path": "/srv/site",
+      "type": "static",
+      "buildCommand": "npm ci && npm run build",
+      "env": {"NODE_ENV": "production"},
+      "healthCheck": {"url": "http://127.0.0.1:8080/health", "timeoutSeconds": 5}
+    },
+    {
+      "name": "api",
+      "path": "/srv/api",
+      "type": "docker",
+      "buildCommand": ["make", "make test"]
+    }
+  ]
+}
+`,
+		"updatectl.toml": `interval = 600
+runOnStart = false
+
+[[projects]]
+name = "site"
+path = "/srv/site"
+type = "static"
+buildCommand = "npm ci && npm run build"
+env = { NODE_ENV = "production" }
+healthCheck = { url = "http://127.0.0.1:8080/health", timeoutSeconds = 5 }
+
+[[projects]]
+name = "api"
+path = "/srv/api"
+type = "docker"
+buildCommand = ["make", "make test"]
+`,
+	}
+	want, err := DecodeConfig("updatectl.yaml", []byte(files["updatectl.yaml"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want.Projects) != 2 || len(want.Projects[1].BuildCommand) != 2 || want.Projects[0].HealthCheck == nil {
+		t.Fatalf("YAML config decoded as %+v", want)
+	}
+	for path, data := range files {
+		t.Run(ConfigFormat(path), func(t *testing.T) {
+			got, err := DecodeConfig(path, []byte(data))
+			if err != nil {
+				t.Fatalf("DecodeConfig: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("DecodeConfig(%s) = %+v, want %+v", path, got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeConfigTOMLErrorHasLine(t *testing.T) {
+	_, err := DecodeConfig("updatectl.toml", []byte("interval = 600\n\n[[projects]]\nname = 5\n"))
+	if err == nil {
+		t.Fatal("DecodeConfig accepted a number as a project name")
+	}
+	if want := "line 4"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q doesn't name %s", err, want)
+	}
+}
@@ -0,0 +1,59 @@
+package updatectl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ProjectCron returns the cron expression that schedules p's checks, or ""
+// if p is checked on an interval. A project's own cron or interval wins over
+// the global settings, and at each level cron wins over interval.
+func (c Config) ProjectCron(p Project) string {
+	switch {
+	case p.Cron != "":
+		return p.Cron
+	case p.Interval > 0:
+		return ""
+	default:
+		return c.Cron
+	}
+}
+
+// NextCheck returns when p should next be checked after now. Projects that
+// keep failing are checked less often, see failureBackoff. Interval checks
+// are spread out by the configured jitter; cron schedules are kept exactly.
+func (c Config) NextCheck(p Project, now time.Time) time.Time {
+	now = now.Add(c.failureBackoff(p))
+	if expr := c.ProjectCron(p); expr != "" {
+		// Expressions are checked when the config is loaded.
+		if sched, err := parseCron(expr); err == nil {
+			return sched.Next(now)
+		}
+	}
+	return now.Add(c.ProjectInterval(p) + c.Jitter())
+}
+
+// parseCron parses a standard five-field cron expression or a descriptor
+// such as @daily. A CRON_TZ=<zone> prefix selects the timezone.
+func parseCron(expr string) (cron.Schedule, error) {
+	sched, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return sched, nil
+}
+
+// WarnCronOverrides logs every place where both cron and an interval are
+// set, since the interval is then ignored.
+func WarnCronOverrides(c Config) {
+	if c.Cron != "" && c.IntervalSeconds() > 0 {
+		Logger.Warn("Both cron and interval are set, using cron", "cron", c.Cron)
+	}
+	for _, p := range c.Projects {
+		if p.Cron != "" && p.Interval > 0 {
+			Logger.Warn("Both cron and interval are set, using cron", "project", p.Name, "cron", p.Cron)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package updatectl
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// resolvedProjects returns c.Projects with c.Defaults applied, leaving
+// c.Projects itself unchanged. Every field the defaults set that a project
+// leaves empty is filled in; env and urlRewrites are merged key by key, with
+// the project's own entries winning.
+func (c Config) resolvedProjects() []Project {
+	if c.Defaults == nil {
+		return c.Projects
+	}
+	projects := make([]Project, len(c.Projects))
+	for i, p := range c.Projects {
+		projects[i] = withDefaults(p, *c.Defaults)
+	}
+	return projects
+}
+
+// withDefaults returns p with its zero fields taken from defaults. Slices,
+// maps and pointed-to settings are copied, so projects never share them.
+func withDefaults(p, defaults Project) Project {
+	pv := reflect.ValueOf(&p).Elem()
+	dv := reflect.ValueOf(defaults)
+	for i := range dv.NumField() {
+		d, f := dv.Field(i), pv.Field(i)
+		if d.IsZero() {
+			continue
+		}
+		switch {
+		case d.Kind() == reflect.Map:
+			merged := reflect.MakeMapWithSize(d.Type(), d.Len()+f.Len())
+			for _, m := range []reflect.Value{d, f} {
+				for iter := m.MapRange(); iter.Next(); {
+					merged.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			f.Set(merged)
+		case !f.IsZero():
+		case d.Kind() == reflect.Slice:
+			f.Set(reflect.AppendSlice(reflect.MakeSlice(d.Type(), 0, d.Len()), d))
+		case d.Kind() == reflect.Pointer:
+			copied := reflect.New(d.Type().Elem())
+			copied.Elem().Set(d.Elem())
+			f.Set(copied)
+		default:
+			f.Set(d)
+		}
+	}
+	return p
+}
+
+// validateDefaults reports the fields set in the defaults block that
+// identify a single project, and so can't be shared.
+func validateDefaults(d Project) []error {
+	var problems []error
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{"name", d.Name != ""},
+		{"path", d.Path != ""},
+		{"repo", d.Repo != ""},
+		{"image", d.Image != ""},
+		{"containerName", d.ContainerName != ""},
+		{"deployPath", d.DeployPath != ""},
+	} {
+		if field.set {
+			problems = append(problems, fmt.Errorf("defaults: %s can't be set for all projects", field.name))
+		}
+	}
+	return problems
+}
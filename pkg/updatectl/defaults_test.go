@@ -0,0 +1,62 @@
+package updatectl
+
+import "testing"
+
+func TestDefaultsBoolOverride(t *testing.T) {
+	files := map[string]string{
+		"updatectl.yaml": `defaults:
+  autoRollback: true
+  submodules: true
+projects:
+  - name: web
+    path: /srv/web
+    type: static
+  - name: api
+    path: /srv/api
+    type: static
+    autoRollback: false
+`,
+		"updatectl.json": `{
+  "defaults": {"autoRollback": true, "submodules": true},
+  "projects": [
+    {"name": "web", "path": "/srv/web", "type": "static"},
+    {"name": "api", "path": "/srv/api", "type": "static", "autoRollback": false}
+  ]
+}
+`,
+		"updatectl.toml": `[defaults]
+autoRollback = true
+submodules = true
+
+[[projects]]
+name = "web"
+path = "/srv/web"
+type = "static"
+
+[[projects]]
+name = "api"
+path = "/srv/api"
+type = "static"
+autoRollback = false
+`,
+	}
+	for path, data := range files {
+		t.Run(ConfigFormat(path), func(t *testing.T) {
+			config, err := DecodeConfig(path, []byte(data))
+			if err != nil {
+				t.Fatalf("DecodeConfig: %v", err)
+			}
+			projects := config.resolvedProjects()
+			web, api := projects[0], projects[1]
+			if !web.RollsBack() || !web.UpdatesSubmodules() {
+				t.Errorf("web: autoRollback %v, submodules %v, want both from defaults", web.RollsBack(), web.UpdatesSubmodules())
+			}
+			if api.RollsBack() {
+				t.Error("api: autoRollback: false overridden by defaults")
+			}
+			if !api.UpdatesSubmodules() {
+				t.Error("api: submodules not taken from defaults")
+			}
+		})
+	}
+}
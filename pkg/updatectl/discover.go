@@ -0,0 +1,77 @@
+package updatectl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// discoveredTypes maps files found at the top of a checkout in ProjectsDir
+// to the project type they suggest, in order of precedence. Checkouts with
+// none of them become static projects.
+var discoveredTypes = []struct {
+	file, projectType string
+}{
+	{"compose.yaml", "docker-compose"},
+	{"compose.yml", "docker-compose"},
+	{"docker-compose.yaml", "docker-compose"},
+	{"docker-compose.yml", "docker-compose"},
+	{"ecosystem.config.js", "pm2"},
+	{"ecosystem.config.cjs", "pm2"},
+}
+
+// discoverProjectsInDir returns a project for every git checkout directly
+// inside dir, named after its directory, with the type guessed from the
+// files in it. Hidden directories are skipped.
+func discoverProjectsInDir(dir string) ([]Project, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var projects []Project
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if !IsGitRepo(path) {
+			continue
+		}
+		p := Project{Name: e.Name(), Path: path, Type: "static"}
+		for _, t := range discoveredTypes {
+			if _, err := os.Stat(filepath.Join(path, t.file)); err == nil {
+				p.Type = t.projectType
+				break
+			}
+		}
+		projects = append(projects, p)
+	}
+	return projects, nil
+}
+
+// addDiscoveredProjects appends the projects found in c.ProjectsDir, if set,
+// to c.Projects. A project listed in the config under the same name or path
+// takes precedence over the one discovered. A projectsDir that can't be read
+// adds nothing; Validate reports it.
+func (c *Config) addDiscoveredProjects() {
+	if c.ProjectsDir == "" || !filepath.IsAbs(c.ProjectsDir) {
+		return
+	}
+	discovered, err := discoverProjectsInDir(c.ProjectsDir)
+	if err != nil {
+		return
+	}
+	names := make(map[string]bool, len(c.Projects))
+	paths := make(map[string]bool, len(c.Projects))
+	for _, p := range c.Projects {
+		names[p.Name] = true
+		if p.Path != "" {
+			paths[filepath.Clean(p.Path)] = true
+		}
+	}
+	for _, p := range discovered {
+		if !names[p.Name] && !paths[p.Path] {
+			c.Projects = append(c.Projects, p)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package updatectl checks git repositories and container images for new
+// versions and deploys them: it pulls, runs the project's build command and
+// restarts the service, as the updatectl command does on each check.
+//
+// A program embedding it loads a config and runs an update cycle:
+//
+//	updatectl.ConfigPath = "/etc/updatectl/updatectl.yaml"
+//	config, err := updatectl.LoadConfig(updatectl.ConfigPath)
+//	if err != nil {
+//		return err
+//	}
+//	return updatectl.RunCycle(ctx, config)
+//
+// UpdateProject updates a single project and RunBuildCommand runs a command
+// the way builds are run. Behaviour that the command-line tool controls with
+// flags, such as dry runs and verbose output, is set in the Options of an
+// Updater, whose methods run updates with them; the package-level functions
+// use the default Options. Where logs go is set through package variables
+// such as Logger. State, locks and build logs are kept next to ConfigPath.
+package updatectl
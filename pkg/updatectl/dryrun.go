@@ -0,0 +1,113 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// dryRunProject logs the steps UpdateProject would take for p without
+// changing anything on disk, in the registry or in the running service.
+func dryRunProject(ctx context.Context, p Project, log *slog.Logger) error {
+	log = log.With("dry_run", true)
+
+	if p.Type == "image" {
+		current, _ := getImageDigest(ctx, p.Image)
+		remote, err := getRemoteImageDigest(ctx, p.Image)
+		if err != nil {
+			log.Warn("Could not check remote digest", "image", p.Image, "error", err)
+		}
+		if current != "" && remote != "" && strings.HasSuffix(current, remote) {
+			log.Info("Image already up to date", "image", p.Image, "digest", remote)
+			return nil
+		}
+		log.Info("Would pull image", "image", p.Image, "local", orDash(current), "remote", orDash(remote))
+		containerName := p.ContainerName
+		if containerName == "" {
+			containerName = p.Name
+		}
+		log.Info("Would restart container", "container", containerName)
+		return nil
+	}
+
+	local := gitHead(ctx, p.Path)
+	if local == "" {
+		log.Error("Not a git checkout", "path", p.Path)
+		return fmt.Errorf("not a git checkout: %s", p.Path)
+	}
+	if err := gitCheckRemote(ctx, p); err != nil {
+		log.Error("Git remote not found", "error", err)
+		return err
+	}
+	if p.Ref != "" {
+		commit, err := gitLookupPin(ctx, p)
+		if err != nil {
+			log.Error("Could not resolve pinned ref", "ref", p.Ref, "error", err)
+			return fmt.Errorf("resolving %s: %w", p.Ref, err)
+		}
+		if commit == local {
+			log.Info("Already on pinned ref", "ref", p.Ref, "commit", local)
+			return nil
+		}
+		log.Info("Would check out pinned ref", "ref", p.Ref, "from", local, "to", commit)
+	} else if p.TracksTags() {
+		tag, commit, err := gitNewTag(ctx, p)
+		if err != nil {
+			log.Error("Could not read remote tags", "error", err)
+			return fmt.Errorf("reading remote tags: %w", err)
+		}
+		if tag == "" {
+			log.Info("No new tags", "pattern", p.tagPattern(), "commit", local)
+			return nil
+		}
+		log.Info("Would check out tag", "tag", tag, "from", local, "to", commit)
+	} else {
+		remote, ref, err := gitRemoteHead(ctx, p)
+		if err != nil {
+			log.Error("Could not read remote HEAD", "error", err)
+			return fmt.Errorf("reading remote HEAD: %w", err)
+		}
+		if remote == local {
+			log.Info("No new commits", "commit", local, "ref", ref)
+			return nil
+		}
+
+		if p.Branch != "" {
+			log.Info("Would reset to branch", "branch", p.Branch, "from", local, "to", remote)
+		} else {
+			log.Info("Would pull", "ref", ref, "strategy", p.pullStrategy(), "from", local, "to", remote)
+		}
+	}
+	if p.PreUpdate != "" {
+		log.Info("Would run pre-update hook", "command", p.PreUpdate)
+	}
+	if len(p.BuildCommand) > 0 {
+		log.Info("Would run build command", "command", p.BuildCommand, "dir", p.BuildPath())
+	}
+	switch p.Type {
+	case "docker":
+	case "static":
+		if p.DeployPath != "" {
+			log.Info("Would publish static site", "from", p.staticSource(), "deployPath", p.DeployPath)
+		}
+	case "docker-compose":
+		log.Info("Would run docker compose pull and up -d", "file", p.ComposeFile)
+	default:
+		log.Info("Would restart project", "type", p.Type)
+	}
+	if p.HealthCheck != nil {
+		log.Info("Would run health check", "url", p.HealthCheck.URL)
+	}
+	if p.PostUpdate != "" {
+		log.Info("Would run post-update hook", "command", p.PostUpdate)
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
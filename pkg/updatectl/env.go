@@ -0,0 +1,73 @@
+package updatectl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// projectEnv returns the KEY=VALUE pairs configured for p: those read from
+// EnvFile followed by Env, so Env wins when a key appears in both.
+func projectEnv(p Project) ([]string, error) {
+	var env []string
+	if p.EnvFile != "" {
+		path := p.EnvFile
+		if !filepath.IsAbs(path) && p.Path != "" {
+			path = filepath.Join(p.Path, path)
+		}
+		fileEnv, err := readEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, fileEnv...)
+	}
+
+	keys := make([]string, 0, len(p.Env))
+	for key := range p.Env {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+p.Env[key])
+	}
+	return env, nil
+}
+
+// readEnvFile parses a dotenv file. Blank lines and # comments are skipped,
+// an optional "export " prefix is allowed, and values may be wrapped in
+// single or double quotes. Everything after the first = is the value, so
+// values may themselves contain =.
+func readEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	defer f.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env = append(env, key+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	return env, nil
+}
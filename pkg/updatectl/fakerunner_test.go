@@ -0,0 +1,63 @@
+package updatectl
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// fakeRunner is a CommandRunner that runs nothing: each command is recorded
+// and answered with the first response whose command is a prefix of its
+// command line. Commands without a response succeed with no output. A
+// response marked once answers a single command, so that a sequence such as
+// HEAD before and after a pull can be scripted.
+type fakeRunner struct {
+	responses []fakeResponse
+
+	mu    sync.Mutex
+	calls []string
+	used  map[int]bool // once responses that have answered
+}
+
+// fakeResponse scripts what a fakeRunner answers for a command.
+type fakeResponse struct {
+	command string // Start of the command line
+	output  string // Standard output, written to Stdout if the command has one
+	err     error
+	once    bool // Answer only one command
+}
+
+// Run implements CommandRunner.
+func (f *fakeRunner) Run(ctx context.Context, cmd Command) ([]byte, error) {
+	line := cmd.String()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, line)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i, r := range f.responses {
+		if !strings.HasPrefix(line, r.command) || f.used[i] {
+			continue
+		}
+		if r.once {
+			if f.used == nil {
+				f.used = make(map[int]bool)
+			}
+			f.used[i] = true
+		}
+		if cmd.Stdout != nil {
+			cmd.Stdout.Write([]byte(r.output))
+			return nil, r.err
+		}
+		return []byte(r.output), r.err
+	}
+	return nil, nil
+}
+
+// Calls returns the command lines run so far, in order.
+func (f *fakeRunner) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
@@ -0,0 +1,565 @@
+package updatectl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitTokenEnv carries a project's token to the inline credential helper so it
+// never appears on a command line or in a file on disk.
+const gitTokenEnv = "UPDATECTL_GIT_TOKEN"
+
+// gitEnv returns the variables every git command runs with: the C locale, so
+// output and error messages are the same on every host regardless of its
+// language settings, and the proxy configured for ctx.
+func gitEnv(ctx context.Context) []string {
+	return append([]string{"LC_ALL=C", "LANG=C"}, proxyEnv(ctx)...)
+}
+
+// GitCommand returns a git command with the environment updatectl runs git
+// with, for programs that run git themselves. updatectl's own git commands
+// go through runGit and the Updater's Runner.
+func GitCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	killProcessTreeOnCancel(cmd)
+	cmd.Env = append(os.Environ(), gitEnv(ctx)...)
+	return cmd
+}
+
+// runGit runs git with args through the Runner of the Updater in ctx and
+// returns its standard output.
+func runGit(ctx context.Context, args ...string) ([]byte, error) {
+	return runCommand(ctx, Command{Name: "git", Args: args, Env: gitEnv(ctx)})
+}
+
+// runGitCombined is runGit, adding git's standard error to the output if
+// it fails.
+func runGitCombined(ctx context.Context, args ...string) ([]byte, error) {
+	return withStderr(runGit(ctx, args...))
+}
+
+// runGitAuth is runGit for a command that talks to p's remote, with p's
+// credentials configured. If SSHKey is set it is used via GIT_SSH_COMMAND
+// and Token is ignored; otherwise a Token is supplied to HTTPS remotes
+// through a credential helper that reads it from the environment.
+// URLRewrites are applied as git url.<mirror>.insteadOf settings.
+func runGitAuth(ctx context.Context, p Project, args ...string) ([]byte, error) {
+	env := gitEnv(ctx)
+	switch {
+	case p.SSHKey != "":
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %q -o IdentitiesOnly=yes", p.SSHKey))
+	case p.Token != "":
+		helper := fmt.Sprintf(`!f() { echo username=x-access-token; echo "password=$%s"; }; f`, gitTokenEnv)
+		// The empty helper resets any helpers from the user's git config first.
+		args = append([]string{"-c", "credential.helper=", "-c", "credential.helper=" + helper}, args...)
+		env = append(env, gitTokenEnv+"="+p.Token)
+	}
+	// Sorted so the command line is the same on every run.
+	for _, from := range slices.Sorted(maps.Keys(p.URLRewrites)) {
+		args = append([]string{"-c", fmt.Sprintf("url.%s.insteadOf=%s", p.URLRewrites[from], from)}, args...)
+	}
+
+	return runCommand(ctx, Command{Name: "git", Args: args, Env: env})
+}
+
+// runGitAuthCombined is runGitAuth, adding git's standard error to the
+// output if it fails.
+func runGitAuthCombined(ctx context.Context, p Project, args ...string) ([]byte, error) {
+	return withStderr(runGitAuth(ctx, p, args...))
+}
+
+// gitHead returns the commit hash checked out at path, or "" if it can't be read.
+func gitHead(ctx context.Context, path string) string {
+	out, err := runGit(ctx, "-C", path, "rev-parse", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Head returns the commit hash checked out at path, read with u's Runner, or
+// "" if it can't be read.
+func (u *Updater) Head(ctx context.Context, path string) string {
+	return gitHead(u.withUpdater(ctx), path)
+}
+
+// gitCommitCount returns the number of commits in from..to, or 0 if unknown.
+func gitCommitCount(ctx context.Context, path, from, to string) int {
+	if from == "" || to == "" {
+		return 0
+	}
+	out, err := runGit(ctx, "-C", path, "rev-list", "--count", from+".."+to)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+	return n
+}
+
+// gitResetToBranch fetches p's remote and hard-resets p's checkout to
+// <remote>/<branch>, so the deployed tree matches the remote exactly regardless
+// of what was checked out before. The combined output of every step that ran
+// is returned.
+func gitResetToBranch(ctx context.Context, p Project) ([]byte, error) {
+	remote := projectRemote(p)
+	return gitSteps(ctx, p, [][]string{
+		{"fetch", remote},
+		{"checkout", p.Branch},
+		{"reset", "--hard", remote + "/" + p.Branch},
+	})
+}
+
+// pullStrategies lists the accepted Project.PullStrategy values.
+var pullStrategies = []string{"ff-only", "rebase", "reset"}
+
+// pullStrategy returns p's PullStrategy, "ff-only" if unset.
+func (p Project) pullStrategy() string {
+	if p.PullStrategy != "" {
+		return p.PullStrategy
+	}
+	return "ff-only"
+}
+
+// errDiverged is returned by gitPull when a fast-forward is impossible
+// because the checkout has commits that its upstream doesn't.
+var errDiverged = errors.New("the checkout has diverged from its upstream and can't be fast-forwarded (set pullStrategy to rebase or reset)")
+
+// gitPull brings p's checkout up to date with its upstream according to
+// p.PullStrategy: a fast-forward only pull (the default), a pull that
+// rebases local commits, or a fetch followed by a hard reset to what was
+// fetched.
+func gitPull(ctx context.Context, p Project) ([]byte, error) {
+	// Without a Remote, pull uses the branch's upstream as configured in
+	// the checkout.
+	var from []string
+	if p.Remote != "" || p.PullStrategy == "reset" {
+		remote, branch, err := gitUpstream(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		from = []string{remote, branch}
+	}
+	switch p.pullStrategy() {
+	case "reset":
+		return gitSteps(ctx, p, [][]string{
+			append([]string{"fetch"}, from...),
+			{"reset", "--hard", "FETCH_HEAD"},
+		})
+	case "rebase":
+		return runGitAuthCombined(ctx, p, append([]string{"-C", p.Path, "pull", "--rebase"}, from...)...)
+	}
+	output, err := runGitAuthCombined(ctx, p, append([]string{"-C", p.Path, "pull", "--ff-only"}, from...)...)
+	if err != nil && !isTransientGitError(output, err) && gitDiverged(ctx, p) {
+		return output, errDiverged
+	}
+	return output, err
+}
+
+// gitDiverged reports whether HEAD in p's checkout is not an ancestor of
+// FETCH_HEAD, which a failed pull leaves pointing at what it fetched.
+func gitDiverged(ctx context.Context, p Project) bool {
+	if !gitHasCommit(ctx, p, "FETCH_HEAD") {
+		return false
+	}
+	_, err := runGit(ctx, "-C", p.Path, "merge-base", "--is-ancestor", "HEAD", "FETCH_HEAD")
+	return err != nil
+}
+
+// gitShallowUpdate fetches only the last p.Depth commits of the branch p
+// deploys and hard-resets the checkout to them. It is used instead of pull or
+// gitResetToBranch when a depth is set, since pulling into a shallow clone
+// has to negotiate history that isn't there.
+func gitShallowUpdate(ctx context.Context, p Project) ([]byte, error) {
+	remote, branch, err := gitUpstream(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	steps := [][]string{{"fetch", "--depth", strconv.Itoa(p.Depth), remote, branch}}
+	if p.Branch != "" {
+		// A shallow clone tracks a single branch, so the local branch may not
+		// exist yet; -B creates or resets it to what was just fetched.
+		steps = append(steps, []string{"checkout", "-f", "-B", p.Branch, "FETCH_HEAD"})
+	} else {
+		steps = append(steps, []string{"reset", "--hard", "FETCH_HEAD"})
+	}
+	return gitSteps(ctx, p, steps)
+}
+
+// gitSteps runs each git command in steps in p's checkout, stopping at the
+// first failure. The combined output of every step that ran is returned.
+func gitSteps(ctx context.Context, p Project, steps [][]string) ([]byte, error) {
+	var output []byte
+	for _, step := range steps {
+		stepOutput, err := runGitAuthCombined(ctx, p, append([]string{"-C", p.Path}, step...)...)
+		output = append(output, stepOutput...)
+		if err != nil {
+			return output, fmt.Errorf("git %s: %w", step[0], err)
+		}
+	}
+	return output, nil
+}
+
+// gitClone clones p's repo into p's path, checking out p's branch if set,
+// truncating history to p's depth if set and naming the remote after p's
+// Remote if set. With SparsePaths, only those directories are checked out
+// and, where the server supports it, only their files are downloaded.
+func gitClone(ctx context.Context, p Project) ([]byte, error) {
+	args := []string{"clone"}
+	if p.Branch != "" {
+		args = append(args, "--branch", p.Branch)
+	}
+	if p.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(p.Depth))
+	}
+	if p.Remote != "" {
+		args = append(args, "--origin", p.Remote)
+	}
+	if len(p.SparsePaths) > 0 {
+		args = append(args, "--sparse", "--filter=blob:none")
+	}
+	args = append(args, "--", p.Repo, p.Path)
+	output, err := runGitAuthCombined(ctx, p, args...)
+	if err != nil || len(p.SparsePaths) == 0 {
+		return output, err
+	}
+	sparseOutput, err := gitSetSparse(ctx, p)
+	output = append(output, sparseOutput...)
+	if err != nil {
+		// Remove the clone so a retry, or the next check, clones again.
+		os.RemoveAll(p.Path)
+		return output, fmt.Errorf("git sparse-checkout: %w", err)
+	}
+	return output, nil
+}
+
+// IsGitRepo reports whether path is the top level of a git checkout.
+func IsGitRepo(path string) bool {
+	_, err := os.Stat(filepath.Join(path, ".git"))
+	return err == nil
+}
+
+// isEmptyDir reports whether path is a directory with nothing in it. The
+// error from reading it is returned as is, so callers can check for
+// fs.ErrNotExist.
+func isEmptyDir(path string) (bool, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
+
+// gitResetHard resets the checkout at path to commit, discarding any local
+// changes. The combined output is returned with any error.
+func gitResetHard(ctx context.Context, path, commit string) ([]byte, error) {
+	return runGitCombined(ctx, "-C", path, "reset", "--hard", commit)
+}
+
+// gitVerifyCommit checks the signature of commit in p's checkout with git
+// verify-commit. GPG keys must be at least marginally trusted. SSH signatures
+// are checked against p's AllowedSigners file if set, otherwise against git's
+// gpg.ssh.allowedSignersFile setting.
+func gitVerifyCommit(ctx context.Context, p Project, commit string) error {
+	args := []string{"-C", p.Path, "-c", "gpg.minTrustLevel=marginal"}
+	if p.AllowedSigners != "" {
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+p.AllowedSigners)
+	}
+	output, err := runGitCombined(ctx, append(args, "verify-commit", commit)...)
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		// verify-commit prints nothing for a commit without a signature.
+		return errors.New("commit is not signed")
+	}
+	return nil
+}
+
+// onDirtyPolicies lists the accepted Project.OnDirty values.
+var onDirtyPolicies = []string{"skip", "stash", "reset"}
+
+// gitDirtyFiles returns the tracked files in the checkout at path that have
+// uncommitted changes. Untracked files, such as build output, don't count,
+// and neither do submodules, which are stale rather than edited after a pull
+// that doesn't update them.
+func gitDirtyFiles(ctx context.Context, path string) ([]string, error) {
+	out, err := runGit(ctx, "-C", path, "status", "--porcelain", "--untracked-files=no", "--ignore-submodules")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for line := range strings.Lines(string(out)) {
+		if len(line) > 3 {
+			files = append(files, strings.TrimSpace(line[3:]))
+		}
+	}
+	return files, nil
+}
+
+// gitPopStash reapplies changes stashed before an update. If they conflict
+// with the new commits the checkout is reset to HEAD and the changes are left
+// in the stash for someone to resolve by hand.
+func gitPopStash(ctx context.Context, p Project, log *slog.Logger) {
+	output, err := runGitCombined(ctx, "-C", p.Path, "stash", "pop")
+	if err == nil {
+		log.Info("Restored stashed local changes")
+		return
+	}
+	log.Warn("Local changes conflict with the update, left them in the stash", "error", err, "output", strings.TrimSpace(string(output)))
+	if output, err := gitResetHard(ctx, p.Path, "HEAD"); err != nil {
+		log.Error("Git reset failed", "error", err, "output", strings.TrimSpace(string(output)))
+	}
+}
+
+// projectRemote returns the git remote p deploys from: Remote, or origin.
+func projectRemote(p Project) string {
+	if p.Remote != "" {
+		return p.Remote
+	}
+	return "origin"
+}
+
+// gitUpstream returns the remote and branch p deploys from. A pinned branch
+// is read from p's remote; otherwise the current branch's upstream is used,
+// with its remote replaced by p's Remote when one is configured.
+func gitUpstream(ctx context.Context, p Project) (string, string, error) {
+	if p.Branch != "" {
+		return projectRemote(p), p.Branch, nil
+	}
+	out, err := runGit(ctx, "-C", p.Path, "rev-parse", "--abbrev-ref", "@{upstream}")
+	if err != nil {
+		if p.Remote == "" {
+			return "", "", fmt.Errorf("no upstream configured for current branch")
+		}
+		// Without an upstream, follow the branch of the same name on Remote.
+		out, err = runGit(ctx, "-C", p.Path, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return "", "", fmt.Errorf("could not read current branch")
+		}
+		return p.Remote, strings.TrimSpace(string(out)), nil
+	}
+	upstream := strings.TrimSpace(string(out))
+	remote, branch, ok := strings.Cut(upstream, "/")
+	if !ok {
+		return "", "", fmt.Errorf("unexpected upstream %q", upstream)
+	}
+	if p.Remote != "" {
+		remote = p.Remote
+	}
+	return remote, branch, nil
+}
+
+// gitCheckRemote returns an error listing the configured remotes if p's
+// remote doesn't exist in its checkout. It only checks when p names a remote
+// explicitly, pins a branch or ref or tracks tags, since otherwise the
+// upstream's remote is used.
+func gitCheckRemote(ctx context.Context, p Project) error {
+	if p.Remote == "" && p.Branch == "" && p.Ref == "" && !p.TracksTags() {
+		return nil
+	}
+	out, err := runGit(ctx, "-C", p.Path, "remote")
+	if err != nil {
+		return fmt.Errorf("git remote: %w", err)
+	}
+	remotes := strings.Fields(string(out))
+	if slices.Contains(remotes, projectRemote(p)) {
+		return nil
+	}
+	if len(remotes) == 0 {
+		return fmt.Errorf("remote %q not found in %s (no remotes configured)", projectRemote(p), p.Path)
+	}
+	return fmt.Errorf("remote %q not found in %s (available: %s)", projectRemote(p), p.Path, strings.Join(remotes, ", "))
+}
+
+// gitFetchUpstream fetches what p would deploy next without touching the
+// checkout, keeping the fetch shallow if p has a depth, and returns the
+// revision to compare HEAD with: FETCH_HEAD for a branch, the pinned commit
+// for a project with a ref, or for a project that tracks tags the new tag, or
+// HEAD itself if there is none.
+func gitFetchUpstream(ctx context.Context, p Project) (string, []byte, error) {
+	if p.Ref != "" {
+		return gitPinnedCommit(ctx, p)
+	}
+	args := []string{"-C", p.Path, "fetch"}
+	if p.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(p.Depth))
+	}
+	if p.TracksTags() {
+		tag, _, err := gitNewTag(ctx, p)
+		if err != nil || tag == "" {
+			return "HEAD", nil, err
+		}
+		ref := "refs/tags/" + tag
+		output, err := runGitAuthCombined(ctx, p, append(args, "--force", projectRemote(p), ref+":"+ref)...)
+		return ref, output, err
+	}
+	remote, branch, err := gitUpstream(ctx, p)
+	if err != nil {
+		return "", nil, err
+	}
+	output, err := runGitAuthCombined(ctx, p, append(args, remote, branch)...)
+	return "FETCH_HEAD", output, err
+}
+
+// incomingCommit fetches what p would deploy next, retrying transient
+// failures, without touching the checkout. It returns the commit checked out
+// now and the one that would be deployed, which are equal when nothing new
+// has arrived.
+func incomingCommit(ctx context.Context, config Config, p Project, log *slog.Logger) (local, remote string, err error) {
+	var rev string
+	output, err := retryGit(ctx, config, p, log, func() (output []byte, err error) {
+		rev, output, err = gitFetchUpstream(ctx, p)
+		return output, err
+	})
+	if err != nil {
+		log.Error("Git fetch failed", "error", err, "output", strings.TrimSpace(string(output)))
+		return "", "", fmt.Errorf("git fetch failed: %w", err)
+	}
+	out, err := runGit(ctx, "-C", p.Path, "rev-parse", rev+"^{commit}")
+	if err != nil {
+		return "", "", fmt.Errorf("could not read %s in %s", rev, p.Path)
+	}
+	return gitHead(ctx, p.Path), strings.TrimSpace(string(out)), nil
+}
+
+// gitIncomingCommits returns the one-line summaries of the commits in rev
+// that HEAD doesn't have yet, newest first.
+func gitIncomingCommits(ctx context.Context, path, rev string) ([]string, error) {
+	out, err := runGit(ctx, "-C", path, "log", "--oneline", "--no-decorate", "HEAD.."+rev)
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+// gitRemoteHead returns the commit the remote branch that p would deploy
+// currently points at, along with the ref it was read from. It uses
+// ls-remote, so nothing in the local checkout is modified.
+func gitRemoteHead(ctx context.Context, p Project) (string, string, error) {
+	remote, branch, err := gitUpstream(ctx, p)
+	if err != nil {
+		return "", "", err
+	}
+
+	ref := "refs/heads/" + branch
+	out, err := runGitAuth(ctx, p, "-C", p.Path, "ls-remote", remote, ref)
+	if err != nil {
+		return "", ref, fmt.Errorf("git ls-remote: %w", err)
+	}
+	hash, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\t")
+	if hash == "" {
+		return "", ref, fmt.Errorf("%s not found on %s", ref, remote)
+	}
+	return hash, remote + "/" + branch, nil
+}
+
+// transientGitErrors are fragments of git's (C locale) output that indicate a
+// network problem worth retrying, as opposed to conflicts or auth failures.
+var transientGitErrors = []string{
+	"Could not resolve host",
+	"Temporary failure in name resolution",
+	"Connection timed out",
+	"Connection refused",
+	"Connection reset",
+	"Operation timed out",
+	"Failed to connect",
+	"The remote end hung up unexpectedly",
+	"early EOF",
+	"RPC failed",
+	"returned error: 502",
+	"returned error: 503",
+	"returned error: 504",
+}
+
+// isTransientGitError reports whether a failed git command is likely to
+// succeed if retried. The exit code alone can't tell: git exits with 128 for
+// fatal errors but git pull reports a failed fetch with 1, the same code as a
+// merge conflict, so the output decides.
+func isTransientGitError(output []byte, err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	for _, fragment := range transientGitErrors {
+		if strings.Contains(string(output), fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitSlots limits how many git network operations run at once across all
+// projects, independently of the update worker pool, so that a high
+// concurrency doesn't flood the git server. The channel is replaced when
+// the configured limit changes; operations already running release the slot
+// of the channel they took it from.
+var gitSlots = struct {
+	sync.Mutex
+	ch chan struct{}
+}{}
+
+// acquireGitSlot waits until fewer than config.gitConcurrency() git network
+// operations are running and returns a function that frees the slot taken.
+func acquireGitSlot(ctx context.Context, config Config, log *slog.Logger) (func(), error) {
+	gitSlots.Lock()
+	if n := config.gitConcurrency(); cap(gitSlots.ch) != n {
+		gitSlots.ch = make(chan struct{}, n)
+	}
+	ch := gitSlots.ch
+	gitSlots.Unlock()
+
+	release := func() { <-ch }
+	select {
+	case ch <- struct{}{}:
+		return release, nil
+	default:
+	}
+	log.Log(ctx, progressLevel(ctx), "Waiting for other git operations to finish", "gitConcurrency", cap(ch))
+	select {
+	case ch <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// retryGit runs fn, retrying transient failures up to the configured number
+// of times with exponential backoff. fn must be a git network operation:
+// each attempt waits for a slot from gitSlots, which is not held during the
+// backoff. The output and error of the last attempt are returned.
+func retryGit(ctx context.Context, config Config, p Project, log *slog.Logger, fn func() ([]byte, error)) ([]byte, error) {
+	delay := config.retryBackoff(p)
+	for attempt := 1; ; attempt++ {
+		release, err := acquireGitSlot(ctx, config, log)
+		if err != nil {
+			return nil, err
+		}
+		output, err := fn()
+		release()
+		if err == nil || attempt > config.retries(p) || !isTransientGitError(output, err) {
+			return output, err
+		}
+
+		log.Warn("Git command failed, retrying", "attempt", attempt, "delay", delay, "error", err, "output", strings.TrimSpace(string(output)))
+		select {
+		case <-ctx.Done():
+			return output, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,65 @@
+package updatectl
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// setGermanLocale gives the test a non-C locale, as on a host whose admin
+// reads German, for the git commands it runs.
+func setGermanLocale(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"LANG", "LC_ALL", "LC_MESSAGES"} {
+		t.Setenv(name, "de_DE.UTF-8")
+	}
+	t.Setenv("LANGUAGE", "de")
+}
+
+func TestGitRunsInCLocale(t *testing.T) {
+	setGermanLocale(t)
+
+	// A shell alias shows the environment git passes to its children.
+	out, err := runGit(context.Background(), "-c", "alias.showenv=!env", "showenv")
+	if err != nil {
+		t.Fatalf("git showenv: %v", err)
+	}
+	env := make(map[string]string)
+	for line := range strings.Lines(string(out)) {
+		if name, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			env[name] = value
+		}
+	}
+	for _, name := range []string{"LC_ALL", "LANG"} {
+		if env[name] != "C" {
+			t.Errorf("%s = %q in git's environment, want C", name, env[name])
+		}
+	}
+}
+
+func TestTransientGitErrorInGermanLocale(t *testing.T) {
+	setGermanLocale(t)
+	t.Setenv("http_proxy", "")
+	t.Setenv("HTTP_PROXY", "")
+
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		// Nothing listens on port 1, so the connection is refused.
+		{"connection refused", []string{"ls-remote", "http://127.0.0.1:1/repo.git"}, true},
+		{"not a repository", []string{"ls-remote", t.TempDir()}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := runGitCombined(context.Background(), tt.args...)
+			if err == nil {
+				t.Fatalf("git %s succeeded", strings.Join(tt.args, " "))
+			}
+			if got := isTransientGitError(output, err); got != tt.want {
+				t.Errorf("isTransientGitError = %v, want %v; output:\n%s", got, tt.want, output)
+			}
+		})
+	}
+}
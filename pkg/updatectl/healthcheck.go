@@ -0,0 +1,90 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// HealthCheck describes an HTTP endpoint that must respond before a deploy
+// counts as successful.
+type HealthCheck struct {
+	URL            string `yaml:"url" json:"url" toml:"url"`
+	ExpectedStatus int    `yaml:"expectedStatus,omitempty" json:"expectedStatus,omitempty" toml:"expectedStatus,omitzero"` // Defaults to 200
+	TimeoutSeconds int    `yaml:"timeoutSeconds,omitempty" json:"timeoutSeconds,omitempty" toml:"timeoutSeconds,omitzero"` // Per-request timeout, defaults to 5
+	Retries        int    `yaml:"retries,omitempty" json:"retries,omitempty" toml:"retries,omitzero"`                      // Extra attempts after the first, defaults to 5
+}
+
+const (
+	defaultHealthTimeout = 5 * time.Second
+	defaultHealthRetries = 5
+	// healthCheckDelay is the pause between health check attempts.
+	healthCheckDelay = 2 * time.Second
+)
+
+func (hc HealthCheck) expectedStatus() int {
+	if hc.ExpectedStatus > 0 {
+		return hc.ExpectedStatus
+	}
+	return http.StatusOK
+}
+
+func (hc HealthCheck) timeout() time.Duration {
+	if hc.TimeoutSeconds > 0 {
+		return time.Duration(hc.TimeoutSeconds) * time.Second
+	}
+	return defaultHealthTimeout
+}
+
+func (hc HealthCheck) retries() int {
+	if hc.Retries > 0 {
+		return hc.Retries
+	}
+	return defaultHealthRetries
+}
+
+// runHealthCheck polls hc.URL until it returns the expected status, giving up
+// once the retries are exhausted or ctx is cancelled.
+func runHealthCheck(ctx context.Context, hc HealthCheck, log *slog.Logger) error {
+	client := &http.Client{Timeout: hc.timeout()}
+	want := hc.expectedStatus()
+	attempts := hc.retries() + 1
+
+	log.Info("Running health check", "url", hc.URL, "expected_status", want)
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(healthCheckDelay):
+			}
+		}
+
+		lastErr = probe(ctx, client, hc.URL, want)
+		if lastErr == nil {
+			log.Info("Health check passed", "url", hc.URL, "attempt", attempt)
+			return nil
+		}
+		log.Debug("Health check attempt failed", "attempt", attempt, "error", lastErr)
+	}
+	return fmt.Errorf("%s after %d attempts: %w", hc.URL, attempts, lastErr)
+}
+
+func probe(ctx context.Context, client *http.Client, url string, want int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != want {
+		return fmt.Errorf("got status %d, want %d", resp.StatusCode, want)
+	}
+	return nil
+}
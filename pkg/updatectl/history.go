@@ -0,0 +1,109 @@
+package updatectl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// historyMaxSize is the size at which the history file is rotated to
+// history.jsonl.1, replacing the previous rotated file.
+const historyMaxSize = 1 << 20
+
+// HistoryEvent is one deploy recorded in the history file.
+type HistoryEvent struct {
+	Project string `json:"project"`
+	// Trigger is "update" for deploys by watch and once, or "build" for
+	// the build command.
+	Trigger  string    `json:"trigger"`
+	From     string    `json:"from,omitempty"` // Commit or image digest before the deploy
+	To       string    `json:"to,omitempty"`   // Commit or image digest deployed
+	Time     time.Time `json:"time"`           // When the deploy started
+	Duration float64   `json:"durationSeconds"`
+	Result   string    `json:"result"` // success or failure
+	Error    string    `json:"error,omitempty"`
+}
+
+// HistoryPath returns the location of the deploy history, history.jsonl
+// in the StateDir.
+func HistoryPath() string {
+	return filepath.Join(StateDir(), "history.jsonl")
+}
+
+// historyMu serializes appends and rotation within this process. Appends from
+// different processes are single O_APPEND writes of one line each.
+var historyMu sync.Mutex
+
+// RecordHistory appends ev to the history file. Failures are only logged, so
+// a read-only state directory never fails a deploy.
+func RecordHistory(ev HistoryEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		Logger.Warn("Failed to record deploy history", "error", err)
+		return
+	}
+	data = append(data, '\n')
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	path := HistoryPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		Logger.Warn("Failed to record deploy history", "path", path, "error", err)
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.Size()+int64(len(data)) > historyMaxSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			Logger.Warn("Failed to rotate deploy history", "path", path, "error", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		Logger.Warn("Failed to record deploy history", "path", path, "error", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		Logger.Warn("Failed to record deploy history", "path", path, "error", err)
+	}
+}
+
+// ReadHistory returns the most recent deploys from the history file, oldest
+// first, including the rotated file. If project is set only its deploys are
+// returned; a limit of zero returns them all. Lines that don't parse are
+// skipped.
+func ReadHistory(project string, limit int) ([]HistoryEvent, error) {
+	var events []HistoryEvent
+	for _, path := range []string{HistoryPath() + ".1", HistoryPath()} {
+		f, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var ev HistoryEvent
+			if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+				continue
+			}
+			if project == "" || ev.Project == project {
+				events = append(events, ev)
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read history %s: %w", path, err)
+		}
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events, nil
+}
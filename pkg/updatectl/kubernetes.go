@@ -0,0 +1,57 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// kubernetesRolloutTimeout bounds how long we wait for a restarted
+// deployment to become ready.
+const kubernetesRolloutTimeout = 5 * time.Minute
+
+// restartKubernetesDeployment applies p's manifests (if configured), restarts
+// its deployment and waits for the rollout to finish. A rollout that doesn't
+// complete is returned as an error.
+func restartKubernetesDeployment(ctx context.Context, p Project, log *slog.Logger, out io.Writer) error {
+	deployment := p.Deployment
+	if deployment == "" {
+		deployment = p.Name
+	}
+
+	kubectl := func(args ...string) error {
+		if p.Namespace != "" {
+			args = append([]string{"--namespace", p.Namespace}, args...)
+		}
+		if _, err := runCommand(ctx, Command{Name: "kubectl", Args: args, Dir: p.Path, Stdout: out, Stderr: out}); err != nil {
+			return fmt.Errorf("kubectl %s: %w", strings.Join(args, " "), err)
+		}
+		return nil
+	}
+
+	if p.Manifest != "" {
+		manifest := p.Manifest
+		if !filepath.IsAbs(manifest) {
+			manifest = filepath.Join(p.Path, manifest)
+		}
+		log.Info("Applying Kubernetes manifests", "manifest", manifest)
+		if err := kubectl("apply", "-f", manifest); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Restarting Kubernetes deployment", "deployment", deployment, "namespace", p.Namespace)
+	if err := kubectl("rollout", "restart", "deployment/"+deployment); err != nil {
+		return err
+	}
+	log.Info("Waiting for rollout", "deployment", deployment)
+	timeout := fmt.Sprintf("--timeout=%s", kubernetesRolloutTimeout)
+	if err := kubectl("rollout", "status", "deployment/"+deployment, timeout); err != nil {
+		return fmt.Errorf("rollout of deployment/%s did not complete: %w", deployment, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package updatectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrProjectLocked is returned by LockProject when another updatectl process
+// still holds the project's lock once the timeout has passed.
+var ErrProjectLocked = errors.New("another updatectl process is updating this project")
+
+// LockDir returns the directory holding per-project lock files: locks/ in
+// the StateDir.
+func LockDir() string {
+	return filepath.Join(StateDir(), "locks")
+}
+
+// ErrInstanceLocked is returned by LockInstance when another updatectl
+// process still holds the instance lock of the config once the timeout has
+// passed.
+var ErrInstanceLocked = errors.New("another updatectl is running against this config")
+
+// InstanceLockPath returns the lock file shared by every updatectl process
+// using the config at ResolveConfigPath: the config file's name with .lock
+// appended, in the StateDir.
+func InstanceLockPath() string {
+	return filepath.Join(StateDir(), filepath.Base(ResolveConfigPath())+".lock")
+}
+
+// LockInstance takes the instance lock of the config, so that state-changing
+// commands such as once and build, and the watch daemon's update cycles,
+// never overlap. Waiting works as in LockProject. The returned function
+// releases the lock.
+func LockInstance(ctx context.Context, timeout time.Duration) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(InstanceLockPath()), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return lockFile(ctx, InstanceLockPath(), timeout, ErrInstanceLocked)
+}
+
+// LockProject takes the lock for the named project, so the watch daemon and
+// manual commands never run git, builds or restarts on the same project at
+// the same time. If the lock is held it retries until timeout (zero means
+// try once) or ctx is done. The returned function releases the lock.
+func LockProject(ctx context.Context, name string, timeout time.Duration) (func(), error) {
+	if err := os.MkdirAll(LockDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	// Lock files are named after the project; keep separators out of the name.
+	file := strings.NewReplacer("/", "_", `\`, "_").Replace(name) + ".lock"
+	return lockFile(ctx, filepath.Join(LockDir(), file), timeout, ErrProjectLocked)
+}
+
+// lockFile takes an advisory lock on the file at path, creating it if
+// needed, and retries until timeout or ctx is done. errLocked is returned if
+// the lock is still held by then.
+func lockFile(ctx context.Context, path string, timeout time.Duration, errLocked error) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", f.Name(), err)
+		}
+		if locked {
+			return func() {
+				unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, errLocked
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package updatectl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Logging settings. The updatectl command sets them from its --quiet,
+// --log-format and --log-level flags.
+var (
+	// Quiet discards command output; only errors are logged.
+	Quiet bool
+	// LogFormat is "text" or "json".
+	LogFormat = "text"
+	// LogLevel is the minimum level of loggers made by NewLogger.
+	LogLevel slog.LevelVar
+
+	// Output is where log records and project output go when updating
+	// projects one at a time.
+	Output io.Writer = os.Stdout
+	// Logger is the package logger, writing to Output.
+	Logger = NewLogger(os.Stdout)
+)
+
+// NewLogger returns a logger writing to w in LogFormat at LogLevel.
+func NewLogger(w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: &LogLevel}
+	if LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// progressLevel is the level for routine progress messages such as "Checking
+// project": shown when the Updater in ctx is Verbose, otherwise only at debug
+// level, so a project with nothing to do produces a single line.
+func progressLevel(ctx context.Context) slog.Level {
+	if updaterFrom(ctx).opts.Verbose {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
+
+// CommandOutput returns w, or a writer that discards everything with --quiet.
+func CommandOutput(w io.Writer) io.Writer {
+	if Quiet {
+		return io.Discard
+	}
+	return w
+}
+
+// commandWriter returns where subprocess output for a project should go. In
+// JSON mode every output line becomes its own log record so the stream stays
+// parseable; otherwise output is passed through untouched. With --quiet it is
+// discarded. The returned function flushes any trailing partial line and must
+// be called once the subprocesses are done.
+func commandWriter(log *slog.Logger, out io.Writer) (io.Writer, func()) {
+	if Quiet {
+		return io.Discard, func() {}
+	}
+	if LogFormat != "json" {
+		return out, func() {}
+	}
+	w := &logLineWriter{log: log}
+	return w, w.flush
+}
+
+// logLineWriter turns each line written to it into an info-level log record.
+type logLineWriter struct {
+	log *slog.Logger
+	buf []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *logLineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *logLineWriter) emit(line string) {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) != "" {
+		w.log.Info(line, "stream", "output")
+	}
+}
+
+// PrefixWriter passes output on to a writer shared with other projects, a
+// whole line at a time and with "[name] " in front of each line. All
+// PrefixWriters on the same writer share one mutex, so lines from projects
+// updating in parallel never run into each other. A partial line is held
+// back until its newline arrives or Flush is called.
+type PrefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+// NewPrefixWriter returns a PrefixWriter for the project called name. An
+// empty name writes lines without a prefix.
+func NewPrefixWriter(w io.Writer, mu *sync.Mutex, name string) *PrefixWriter {
+	pw := &PrefixWriter{mu: mu, w: w}
+	if name != "" {
+		pw.prefix = []byte("[" + name + "] ")
+	}
+	return pw
+}
+
+func (pw *PrefixWriter) Write(p []byte) (int, error) {
+	pw.buf = append(pw.buf, p...)
+	end := bytes.LastIndexByte(pw.buf, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	if err := pw.writeLines(pw.buf[:end+1]); err != nil {
+		return 0, err
+	}
+	pw.buf = append(pw.buf[:0], pw.buf[end+1:]...)
+	return len(p), nil
+}
+
+// Flush writes out any partial line still held back, ending it with a newline.
+func (pw *PrefixWriter) Flush() error {
+	if len(pw.buf) == 0 {
+		return nil
+	}
+	err := pw.writeLines(append(pw.buf, '\n'))
+	pw.buf = nil
+	return err
+}
+
+// writeLines writes complete, newline-terminated lines with their prefixes
+// in a single call to the underlying writer.
+func (pw *PrefixWriter) writeLines(lines []byte) error {
+	var out []byte
+	for line := range bytes.Lines(lines) {
+		out = append(out, pw.prefix...)
+		out = append(out, line...)
+	}
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	_, err := pw.w.Write(out)
+	return err
+}
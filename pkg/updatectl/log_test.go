@@ -0,0 +1,87 @@
+package updatectl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		writes []string
+		flush  bool
+		want   string
+	}{
+		{"whole lines", "app", []string{"one\ntwo\n"}, false, "[app] one\n[app] two\n"},
+		{"line split across writes", "app", []string{"hel", "lo wor", "ld\n"}, false, "[app] hello world\n"},
+		{"partial line held back", "app", []string{"done\nprogress 50%"}, false, "[app] done\n"},
+		{"trailing line flushed", "app", []string{"done\nprogress 50%"}, true, "[app] done\n[app] progress 50%\n"},
+		{"flush with nothing held", "app", []string{"done\n"}, true, "[app] done\n"},
+		{"empty line", "app", []string{"\n"}, false, "[app] \n"},
+		{"no prefix", "", []string{"a", "b\nc"}, true, "ab\nc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewPrefixWriter(&out, &sync.Mutex{}, tt.prefix)
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			if tt.flush {
+				if err := w.Flush(); err != nil {
+					t.Fatalf("Flush: %v", err)
+				}
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixWriterConcurrent(t *testing.T) {
+	const writers, lines = 8, 200
+	var out bytes.Buffer
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("p%d", i)
+			w := NewPrefixWriter(&out, &mu, name)
+			defer w.Flush()
+			// Each line is written in pieces, so lines from different
+			// writers would mix if they weren't held back until complete.
+			for j := range lines {
+				fmt.Fprintf(w, "%s line ", name)
+				fmt.Fprintf(w, "%d", j)
+				if j < lines-1 {
+					w.Write([]byte("\n"))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := make(map[string]int)
+	for line := range strings.Lines(out.String()) {
+		var prefix, name string
+		var j int
+		if _, err := fmt.Sscanf(line, "[%s %s line %d\n", &prefix, &name, &j); err != nil || prefix != name+"]" {
+			t.Fatalf("mixed line %q", line)
+		}
+		count[name]++
+	}
+	for i := range writers {
+		if name := fmt.Sprintf("p%d", i); count[name] != lines {
+			t.Errorf("%s wrote %d lines, want %d", name, count[name], lines)
+		}
+	}
+}
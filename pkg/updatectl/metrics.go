@@ -0,0 +1,171 @@
+package updatectl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// buildDurationBuckets are the upper bounds, in seconds, of the
+// updatectl_build_duration_seconds histogram.
+var buildDurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// daemonMetrics holds the metrics exposed on the metrics endpoint, labelled
+// by project name. The daemon updates them from UpdateProject.
+type daemonMetrics struct {
+	registry    *prometheus.Registry
+	updates     *prometheus.CounterVec
+	checkErrors *prometheus.CounterVec
+	lastUpdate  *prometheus.GaugeVec
+	builds      *prometheus.HistogramVec
+}
+
+func newDaemonMetrics() *daemonMetrics {
+	m := &daemonMetrics{
+		registry: prometheus.NewRegistry(),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "updatectl_updates_total",
+			Help: "Deploys attempted, by project and result.",
+		}, []string{"project", "result"}),
+		checkErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "updatectl_check_errors_total",
+			Help: "Checks that ended in an error, by project.",
+		}, []string{"project"}),
+		lastUpdate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "updatectl_last_update_timestamp",
+			Help: "Unix time of the last successful deploy.",
+		}, []string{"project"}),
+		builds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "updatectl_build_duration_seconds",
+			Help:    "Time taken by build commands.",
+			Buckets: buildDurationBuckets,
+		}, []string{"project"}),
+	}
+	m.registry.MustRegister(m.updates, m.checkErrors, m.lastUpdate, m.builds)
+	return m
+}
+
+// stuckCycles is how many cycle intervals may pass without a completed cycle
+// before /healthz reports the daemon as stuck.
+const stuckCycles = 3
+
+// cycleTracker records when update cycles complete, for /healthz.
+type cycleTracker struct {
+	mu          sync.Mutex
+	started     time.Time
+	interval    time.Duration
+	lastCycle   time.Time
+	lastSuccess time.Time
+}
+
+// SetCycleInterval tells /healthz how often the daemon runs an update cycle.
+// It reports the daemon as stuck once no cycle of u has completed for three
+// intervals. With no interval set it always reports healthy.
+func (u *Updater) SetCycleInterval(d time.Duration) {
+	u.cycles.mu.Lock()
+	defer u.cycles.mu.Unlock()
+	u.cycles.interval = d
+}
+
+// record notes a completed cycle, which succeeded if no project failed.
+func (t *cycleTracker) record(success bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastCycle = time.Now()
+	if success {
+		t.lastSuccess = t.lastCycle
+	}
+}
+
+// ServeHTTP implements /healthz: 200 while cycles keep completing, 503 once
+// the daemon appears stuck. Until the first cycle completes, the time is
+// counted from when the daemon started.
+func (t *cycleTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Copy what's needed and write the response after unlocking, so a slow
+	// client can't hold up the daemon recording a cycle.
+	t.mu.Lock()
+	started, interval, lastCycle, lastSuccess := t.started, t.interval, t.lastCycle, t.lastSuccess
+	t.mu.Unlock()
+
+	now := time.Now()
+	status := struct {
+		Status              string `json:"status"`
+		SecondsSinceCycle   *int   `json:"secondsSinceCycle"`
+		SecondsSinceSuccess *int   `json:"secondsSinceSuccess"`
+		ThresholdSeconds    int    `json:"thresholdSeconds,omitempty"`
+	}{Status: "ok"}
+	secondsSince := func(t time.Time) *int {
+		if t.IsZero() {
+			return nil
+		}
+		s := int(now.Sub(t).Seconds())
+		return &s
+	}
+	status.SecondsSinceCycle = secondsSince(lastCycle)
+	status.SecondsSinceSuccess = secondsSince(lastSuccess)
+
+	last := lastCycle
+	if last.IsZero() {
+		last = started
+	}
+	code := http.StatusOK
+	if interval > 0 {
+		threshold := stuckCycles * interval
+		status.ThresholdSeconds = int(threshold.Seconds())
+		if now.Sub(last) > threshold {
+			status.Status = "stuck"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// recordUpdate counts a deploy attempt for project, and on success sets its
+// last update time.
+func (m *daemonMetrics) recordUpdate(project string, success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+		m.lastUpdate.WithLabelValues(project).Set(float64(time.Now().Unix()))
+	}
+	m.updates.WithLabelValues(project, result).Inc()
+}
+
+// recordCheckError counts a check of project that ended in an error.
+func (m *daemonMetrics) recordCheckError(project string) {
+	m.checkErrors.WithLabelValues(project).Inc()
+}
+
+// observeBuild records how long a build of project took.
+func (m *daemonMetrics) observeBuild(project string, d time.Duration) {
+	m.builds.WithLabelValues(project).Observe(d.Seconds())
+}
+
+// ServeMetrics serves the metrics of u's updates on addr at /metrics, and the
+// daemon's liveness at /healthz, until ctx is done, then shuts the server
+// down.
+func (u *Updater) ServeMetrics(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(u.metrics.registry, promhttp.HandlerOpts{}))
+	mux.Handle("/healthz", u.cycles)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
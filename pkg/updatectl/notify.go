@@ -0,0 +1,179 @@
+package updatectl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// NotifyConfig configures where update outcomes are reported.
+type NotifyConfig struct {
+	Webhook   string           `yaml:"webhook,omitempty" json:"webhook,omitempty" toml:"webhook,omitempty"`       // URL that receives a JSON POST for each update outcome
+	Events    []string         `yaml:"events,omitempty" json:"events,omitempty" toml:"events,omitempty"`          // Events to send to webhook: success, failure, behind (default: all)
+	Notifiers []NotifierConfig `yaml:"notifiers,omitempty" json:"notifiers,omitempty" toml:"notifiers,omitempty"` // Additional notification targets
+}
+
+// NotifierConfig configures a single notification target.
+type NotifierConfig struct {
+	Type   string   `yaml:"type,omitempty" json:"type,omitempty" toml:"type,omitempty"`       // webhook, slack or discord
+	URL    string   `yaml:"url,omitempty" json:"url,omitempty" toml:"url,omitempty"`          // Webhook URL for the target
+	Events []string `yaml:"events,omitempty" json:"events,omitempty" toml:"events,omitempty"` // Events to send: success, failure, behind (default: all)
+}
+
+// notifyEvent describes the outcome of updating a project. It is also the
+// JSON body posted to generic webhooks.
+type notifyEvent struct {
+	Project   string    `json:"project"`
+	Event     string    `json:"event"`
+	Commit    string    `json:"commit,omitempty"`
+	Commits   int       `json:"commits,omitempty"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier delivers update events to an external service.
+type Notifier interface {
+	Send(ctx context.Context, ev notifyEvent) error
+}
+
+type webhookNotifier struct{ url string }
+
+func (n webhookNotifier) Send(ctx context.Context, ev notifyEvent) error {
+	return postJSON(ctx, n.url, ev)
+}
+
+type slackNotifier struct{ url string }
+
+func (n slackNotifier) Send(ctx context.Context, ev notifyEvent) error {
+	return postJSON(ctx, n.url, map[string]string{"text": ev.message()})
+}
+
+type discordNotifier struct{ url string }
+
+func (n discordNotifier) Send(ctx context.Context, ev notifyEvent) error {
+	return postJSON(ctx, n.url, map[string]string{"content": ev.message()})
+}
+
+// message formats ev as a short chat message.
+func (ev notifyEvent) message() string {
+	if ev.Event == "behind" {
+		if ev.Commits > 0 {
+			return fmt.Sprintf("⏳ %s is %d commit(s) behind (latest %s)", ev.Project, ev.Commits, shortHash(ev.Commit))
+		}
+		return fmt.Sprintf("⏳ %s has a new version available: %s", ev.Project, shortHash(ev.Commit))
+	}
+	if !ev.Success {
+		return fmt.Sprintf("❌ update failed for %s: %s", ev.Project, ev.Error)
+	}
+	msg := fmt.Sprintf("✅ deployed %s", ev.Project)
+	if ev.Commit != "" {
+		msg += " → " + shortHash(ev.Commit)
+	}
+	if ev.Commits == 1 {
+		msg += " (1 new commit)"
+	} else if ev.Commits > 1 {
+		msg += fmt.Sprintf(" (%d new commits)", ev.Commits)
+	}
+	return msg
+}
+
+func shortHash(hash string) string {
+	// Image digests look like "sha256:<hex>"; keep the algorithm visible.
+	if len(hash) > 7 && !strings.Contains(hash, ":") {
+		return hash[:7]
+	}
+	if len(hash) > 19 {
+		return hash[:19]
+	}
+	return hash
+}
+
+const notifyTimeout = 5 * time.Second
+
+var notifyClient = &http.Client{
+	Timeout:   notifyTimeout,
+	Transport: &http.Transport{Proxy: proxyForRequest},
+}
+
+type notifyTarget struct {
+	notifier Notifier
+	kind     string
+	events   []string
+}
+
+// targets returns every configured notifier along with its event filter.
+func (n NotifyConfig) targets() []notifyTarget {
+	var targets []notifyTarget
+	if n.Webhook != "" {
+		targets = append(targets, notifyTarget{webhookNotifier{n.Webhook}, "webhook", n.Events})
+	}
+	for _, nc := range n.Notifiers {
+		var notifier Notifier
+		switch nc.Type {
+		case "webhook", "":
+			notifier = webhookNotifier{nc.URL}
+		case "slack":
+			notifier = slackNotifier{nc.URL}
+		case "discord":
+			notifier = discordNotifier{nc.URL}
+		default:
+			Logger.Warn("Unknown notifier type", "type", nc.Type)
+			continue
+		}
+		targets = append(targets, notifyTarget{notifier, nc.Type, nc.Events})
+	}
+	return targets
+}
+
+// notify reports ev to every configured notifier. It is best-effort: failures
+// are logged as warnings and never affect the update itself. Unless ev names
+// its event, it is a success or failure depending on ev.Success. Failures are
+// reported even if ctx, which carries the proxy, has been cancelled.
+func notify(ctx context.Context, n NotifyConfig, ev notifyEvent, log *slog.Logger) {
+	ctx = context.WithoutCancel(ctx)
+	switch {
+	case ev.Event != "":
+	case ev.Success:
+		ev.Event = "success"
+	default:
+		ev.Event = "failure"
+	}
+	ev.Timestamp = time.Now()
+
+	for _, t := range n.targets() {
+		if len(t.events) > 0 && !slices.Contains(t.events, ev.Event) {
+			continue
+		}
+		if err := t.notifier.Send(ctx, ev); err != nil {
+			log.Warn("Failed to send notification", "notifier", t.kind, "error", err)
+		}
+	}
+}
+
+func postJSON(ctx context.Context, url string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+//go:build !windows
+
+package updatectl
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// killProcessTreeOnCancel starts cmd in its own process group and makes
+// context cancellation send SIGKILL to the whole group, so children spawned
+// by the shell (docker, node, ...) don't outlive it.
+func killProcessTreeOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = processWaitDelay
+}
+
+// lookupRunAsUser resolves a project's runAsUser to the credentials its
+// commands run with, including the user's supplementary groups.
+func lookupRunAsUser(name string) (*syscall.Credential, *user.User, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("runAsUser: %w", err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("runAsUser %s: bad uid %q", name, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("runAsUser %s: bad gid %q", name, u.Gid)
+	}
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+				cred.Groups = append(cred.Groups, uint32(g))
+			}
+		}
+	}
+	return cred, u, nil
+}
+
+// runAsUser makes cmd run as the named user, with HOME, USER and LOGNAME set
+// to match. It must be called after killProcessTreeOnCancel. Running as the
+// daemon's own user is a no-op, so the setting doesn't require root then.
+func runAsUser(cmd *exec.Cmd, name string) error {
+	cred, u, err := lookupRunAsUser(name)
+	if err != nil {
+		return err
+	}
+	if int(cred.Uid) == os.Getuid() {
+		return nil
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = cred
+	cmd.Env = append(cmd.Environ(), "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+	return nil
+}
+
+// checkRunAsUser reports whether name is a user commands can run as.
+func checkRunAsUser(name string) error {
+	_, _, err := lookupRunAsUser(name)
+	return err
+}
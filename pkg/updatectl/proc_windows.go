@@ -0,0 +1,31 @@
+//go:build windows
+
+package updatectl
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+)
+
+// killProcessTreeOnCancel makes context cancellation kill cmd and every
+// process it started using taskkill /T, since Windows has no process groups
+// that can be signalled as a unit.
+func killProcessTreeOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+	cmd.WaitDelay = processWaitDelay
+}
+
+// errRunAsUserUnsupported is returned for runAsUser on Windows, where
+// commands can't switch to another account without its password.
+var errRunAsUserUnsupported = errors.New("runAsUser is not supported on Windows")
+
+func runAsUser(cmd *exec.Cmd, name string) error {
+	return errRunAsUserUnsupported
+}
+
+func checkRunAsUser(name string) error {
+	return errRunAsUserUnsupported
+}
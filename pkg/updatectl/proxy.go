@@ -0,0 +1,113 @@
+package updatectl
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// proxySetting holds the Proxy of the config loaded last. It is used by git
+// commands and requests run outside an update, such as by the CLI; an update
+// uses the proxy of the config it runs with, carried in its ctx.
+var proxySetting = struct {
+	sync.Mutex
+	url *url.URL
+}{}
+
+// parseProxy returns the proxy URL raw, or nil if raw is empty.
+func parseProxy(raw string) *url.URL {
+	if raw == "" {
+		return nil
+	}
+	u, _ := url.Parse(raw)
+	return u
+}
+
+// setProxy makes raw the proxy for git and notifications outside an update.
+// An empty raw leaves both to the proxy variables in the environment.
+func setProxy(raw string) {
+	proxySetting.Lock()
+	proxySetting.url = parseProxy(raw)
+	proxySetting.Unlock()
+}
+
+type proxyKey struct{}
+
+// withProxy returns ctx carrying raw as the proxy for the git commands and
+// requests run with it, so updates with different configs can run at once.
+func withProxy(ctx context.Context, raw string) context.Context {
+	return context.WithValue(ctx, proxyKey{}, parseProxy(raw))
+}
+
+// configuredProxy returns the proxy in ctx, or else the one set by setProxy,
+// or nil.
+func configuredProxy(ctx context.Context) *url.URL {
+	if u, ok := ctx.Value(proxyKey{}).(*url.URL); ok {
+		return u
+	}
+	proxySetting.Lock()
+	defer proxySetting.Unlock()
+	return proxySetting.url
+}
+
+// proxyEnv returns the variables that point git at the proxy configured for
+// ctx. NO_PROXY is inherited from the environment, and git honors it itself.
+func proxyEnv(ctx context.Context) []string {
+	u := configuredProxy(ctx)
+	if u == nil {
+		return nil
+	}
+	s := u.String()
+	return []string{"HTTP_PROXY=" + s, "HTTPS_PROXY=" + s, "http_proxy=" + s, "https_proxy=" + s}
+}
+
+// proxyForRequest picks the proxy for a notification or CI request: the one
+// configured for the request's context unless NO_PROXY exempts the host, or
+// else the one from the environment.
+func proxyForRequest(req *http.Request) (*url.URL, error) {
+	if u := configuredProxy(req.Context()); u != nil {
+		if noProxy(req.URL.Hostname()) {
+			return nil, nil
+		}
+		return u, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// noProxy reports whether NO_PROXY (or no_proxy) lists host, by name, as a
+// parent domain, as an IP address or CIDR range, or with "*".
+func noProxy(host string) bool {
+	list := os.Getenv("NO_PROXY")
+	if list == "" {
+		list = os.Getenv("no_proxy")
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
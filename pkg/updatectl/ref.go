@@ -0,0 +1,130 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fullCommitHash matches a complete SHA-1 or SHA-256 commit hash.
+var fullCommitHash = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// gitHasCommit reports whether rev names a commit present in p's checkout.
+func gitHasCommit(ctx context.Context, p Project, rev string) bool {
+	_, err := runGit(ctx, "-C", p.Path, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	return err == nil
+}
+
+// gitFetchRef fetches rev from p's remote, keeping the fetch shallow if p has
+// a depth, and returns the commit it names. rev may be a branch or tag name
+// or a commit hash. Servers that refuse to send a commit by its hash, and
+// abbreviated hashes, are handled by fetching all branches and tags and
+// looking rev up locally.
+func gitFetchRef(ctx context.Context, p Project, rev string) (string, []byte, error) {
+	fetch := []string{"-C", p.Path, "fetch"}
+	if p.Depth > 0 {
+		fetch = append(fetch, "--depth", strconv.Itoa(p.Depth))
+	}
+	output, err := runGitAuthCombined(ctx, p, append(fetch, projectRemote(p), rev)...)
+	if err == nil {
+		out, err := runGit(ctx, "-C", p.Path, "rev-parse", "FETCH_HEAD^{commit}")
+		if err != nil {
+			return "", output, fmt.Errorf("could not read the commit fetched for %s", rev)
+		}
+		return strings.TrimSpace(string(out)), output, nil
+	}
+
+	more, err := runGitAuthCombined(ctx, p, append(fetch, "--tags", projectRemote(p))...)
+	output = append(output, more...)
+	if err != nil {
+		return "", output, err
+	}
+	out, err := runGit(ctx, "-C", p.Path, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", output, fmt.Errorf("ref %s not found on %s", rev, projectRemote(p))
+	}
+	return strings.TrimSpace(string(out)), output, nil
+}
+
+// gitPinnedCommit returns the commit p's Ref pins it to, fetching it if the
+// checkout doesn't have it. A ref is only looked up on the remote the first
+// time it appears in the config: the commit it resolved to is kept in the
+// state file, so a pinned branch stays where it was until Ref changes.
+func gitPinnedCommit(ctx context.Context, p Project) (string, []byte, error) {
+	commit := ""
+	if ps := projectState(p.Name); ps.PinnedRef == p.Ref {
+		commit = ps.PinnedCommit
+	} else if fullCommitHash.MatchString(p.Ref) {
+		commit = p.Ref
+	}
+	if commit != "" {
+		if gitHasCommit(ctx, p, commit) {
+			return commit, nil, nil
+		}
+		_, output, err := gitFetchRef(ctx, p, commit)
+		return commit, output, err
+	}
+
+	commit, output, err := gitFetchRef(ctx, p, p.Ref)
+	if err != nil {
+		return "", output, err
+	}
+	recordPin(p.Name, p.Ref, commit)
+	return commit, output, nil
+}
+
+// gitLookupPin returns the commit p's Ref would pin it to without fetching
+// or recording anything, for dry runs: the commit already recorded, or else
+// the one the remote reports for the ref.
+func gitLookupPin(ctx context.Context, p Project) (string, error) {
+	if ps := projectState(p.Name); ps.PinnedRef == p.Ref {
+		return ps.PinnedCommit, nil
+	}
+	if fullCommitHash.MatchString(p.Ref) {
+		return p.Ref, nil
+	}
+	out, err := runGitAuth(ctx, p, "-C", p.Path, "ls-remote", projectRemote(p), p.Ref, p.Ref+"^{}")
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote: %w", err)
+	}
+	// An annotated tag is listed as the tag object and, with ^{} appended,
+	// the commit it points at, which is what gets checked out.
+	var commit string
+	for line := range strings.Lines(string(out)) {
+		hash, ref, _ := strings.Cut(strings.TrimSpace(line), "\t")
+		if commit == "" || strings.HasSuffix(ref, "^{}") {
+			commit = hash
+		}
+	}
+	if commit == "" {
+		// Possibly an abbreviated hash, which only the checkout can expand.
+		out, err := runGit(ctx, "-C", p.Path, "rev-parse", "--verify", "--quiet", p.Ref+"^{commit}")
+		if err != nil {
+			return "", fmt.Errorf("ref %s not found on %s", p.Ref, projectRemote(p))
+		}
+		commit = strings.TrimSpace(string(out))
+	}
+	return commit, nil
+}
+
+// checkoutPinnedRef checks out the commit p is pinned to as a detached HEAD,
+// retrying transient failures, and returns the output of the git commands
+// that ran. Nothing is checked out if HEAD is already there.
+func checkoutPinnedRef(ctx context.Context, config Config, p Project, log *slog.Logger) ([]byte, error) {
+	var commit string
+	output, err := retryGit(ctx, config, p, log, func() (output []byte, err error) {
+		if commit, output, err = gitPinnedCommit(ctx, p); err != nil || commit == gitHead(ctx, p.Path) {
+			return output, err
+		}
+		more, err := runGitCombined(ctx, "-C", p.Path, "checkout", "--detach", commit)
+		return append(output, more...), err
+	})
+	if err != nil {
+		log.Error("Git checkout of pinned ref failed", "ref", p.Ref, "error", err, "output", strings.TrimSpace(string(output)))
+		return output, err
+	}
+	return output, nil
+}
@@ -0,0 +1,77 @@
+package updatectl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// RestartProject performs the type-specific restart action for p.
+func (u *Updater) RestartProject(ctx context.Context, p Project, log *slog.Logger, out io.Writer) error {
+	ctx = u.withUpdater(ctx)
+	var cmd Command
+	switch p.Type {
+	case "pm2":
+		log.Info("Restarting PM2 process")
+		// pm2 keeps a process list per user, so restart the user's.
+		cmd = Command{Name: "pm2", Args: []string{"restart", p.Name}, User: p.RunAsUser}
+	case "docker":
+		log.Info("Restarting Docker Compose services", "path", p.Path)
+		cmd = Command{Name: "docker", Args: []string{"compose", "restart"}, Dir: p.Path}
+	case "docker-compose":
+		log.Info("Restarting Docker Compose services", "path", p.Path, "file", p.ComposeFile)
+		return runCompose(ctx, p, out, "restart")
+	case "image":
+		containerName := p.ContainerName
+		if containerName == "" {
+			containerName = p.Name
+		}
+		log.Info("Restarting container", "container", containerName)
+		cmd = Command{Name: "docker", Args: []string{"restart", containerName}}
+	case "systemd":
+		return restartSystemdService(ctx, p, log, out)
+	case "kubernetes":
+		return restartKubernetesDeployment(ctx, p, log, out)
+	case "static":
+		// Static projects are served straight from disk unless they
+		// publish to a deploy path.
+		if p.DeployPath == "" {
+			return nil
+		}
+		return publishStatic(ctx, p, log, out)
+	default:
+		return fmt.Errorf("unknown project type %q", p.Type)
+	}
+
+	cmd.Stdout = out
+	cmd.Stderr = out
+	_, err := runCommand(ctx, cmd)
+	return err
+}
+
+// restartSystemdService runs systemctl restart for p's unit, including
+// systemctl's stderr in the returned error.
+func restartSystemdService(ctx context.Context, p Project, log *slog.Logger, out io.Writer) error {
+	service := p.ServiceName
+	if service == "" {
+		service = p.Name
+	}
+	if strings.TrimSuffix(service, ".service") == "updatectl" {
+		// Restarting our own daemon mid-cycle would kill this update.
+		return fmt.Errorf("refusing to restart the updatectl service itself")
+	}
+
+	log.Info("Restarting systemd service", "service", service)
+	var stderr bytes.Buffer
+	cmd := Command{Name: "systemctl", Args: []string{"restart", service}, Stdout: out, Stderr: io.MultiWriter(out, &stderr)}
+	if _, err := runCommand(ctx, cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("systemctl restart %s: %w: %s", service, err, msg)
+		}
+		return fmt.Errorf("systemctl restart %s: %w", service, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// handleDeployFailure records a failed build or restart of commit after and,
+// if p has AutoRollback enabled, restores the checkout to before. The
+// returned error is what UpdateProject should report.
+func handleDeployFailure(ctx context.Context, config Config, p Project, before, after string, failure error, log *slog.Logger, out io.Writer) error {
+	// There is nothing to roll back to after a fresh clone.
+	if !p.RollsBack() || before == "" || ctx.Err() != nil {
+		recordFailure(p.Name, failure)
+		return failure
+	}
+
+	if err := rollback(ctx, config, p, before, log, out); err != nil {
+		log.Error("Rollback failed, project may be broken", "to", before, "error", err)
+		failure = fmt.Errorf("%w; rollback failed: %v", failure, err)
+		recordFailure(p.Name, failure)
+		return failure
+	}
+
+	log.Warn("ROLLED BACK", "from", after, "to", before)
+	recordRollback(p.Name, after, failure)
+	return fmt.Errorf("%w (rolled back to %s)", failure, shortHash(before))
+}
+
+// rollback hard-resets p's checkout to commit, then rebuilds and restarts it
+// so the last known good version is running again. Update hooks are not run.
+func rollback(ctx context.Context, config Config, p Project, commit string, log *slog.Logger, out io.Writer) error {
+	log.Warn("Rolling back to previous commit", "commit", commit)
+	if output, err := gitResetHard(ctx, p.Path, commit); err != nil {
+		return fmt.Errorf("git reset: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if len(p.BuildCommand) > 0 {
+		log.Info("Rebuilding previous commit", "command", p.BuildCommand)
+		if err := updaterFrom(ctx).RunBuild(ctx, config, p, out); err != nil {
+			return fmt.Errorf("build: %w", err)
+		}
+	}
+	if err := deployProject(ctx, p, log, out); err != nil {
+		return fmt.Errorf("restart: %w", err)
+	}
+	return nil
+}
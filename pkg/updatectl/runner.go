@@ -0,0 +1,81 @@
+package updatectl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Command is an external program for a CommandRunner to run.
+type Command struct {
+	Name string
+	Args []string
+	// Dir is the directory the command runs in, the current one if empty.
+	Dir string
+	// Env holds KEY=VALUE pairs added to the inherited environment.
+	Env []string
+	// User is the user the command runs as, or "" for updatectl's own.
+	// Running as another user is only supported on Unix.
+	User string
+	// Stdout and Stderr, when Stdout is set, receive the command's output
+	// as it is produced, such as a build's, and Run returns no output.
+	Stdout, Stderr io.Writer
+}
+
+// String returns the command line: the program and its arguments, separated
+// by spaces.
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// CommandRunner runs the external programs an update uses: git, build
+// commands and hooks, docker, kubectl, pm2, rsync and the like.
+type CommandRunner interface {
+	// Run runs cmd. Unless cmd.Stdout is set, it returns the command's
+	// standard output. The standard error of a failed command is read from
+	// the error if it is an *exec.ExitError; other runners may include it
+	// in the output instead.
+	Run(ctx context.Context, cmd Command) ([]byte, error)
+}
+
+// ExecRunner is the CommandRunner that runs real programs. A command still
+// running when ctx is cancelled is killed together with its children.
+type ExecRunner struct{}
+
+// Run implements CommandRunner.
+func (ExecRunner) Run(ctx context.Context, c Command) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	killProcessTreeOnCancel(cmd)
+	cmd.Dir = c.Dir
+	if c.User != "" {
+		if err := runAsUser(cmd, c.User); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.Env) > 0 {
+		cmd.Env = append(cmd.Environ(), c.Env...)
+	}
+	cmd.Stderr = c.Stderr
+	if c.Stdout != nil {
+		cmd.Stdout = c.Stdout
+		return nil, cmd.Run()
+	}
+	return cmd.Output()
+}
+
+// runCommand runs cmd through the Runner of the Updater in ctx.
+func runCommand(ctx context.Context, cmd Command) ([]byte, error) {
+	return updaterFrom(ctx).opts.Runner.Run(ctx, cmd)
+}
+
+// withStderr appends the standard error carried by a failed command's error
+// to its output, for logging it and for recognizing transient failures.
+func withStderr(output []byte, err error) ([]byte, error) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		output = append(output, exitErr.Stderr...)
+	}
+	return output, err
+}
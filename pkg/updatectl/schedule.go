@@ -0,0 +1,186 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Timezone names must resolve on hosts without zoneinfo, such as Windows.
+)
+
+// Schedule restricts deploys to maintenance windows. Changes are still
+// detected outside the windows, but building and restarting waits until the
+// next allowed hour.
+type Schedule struct {
+	AllowedHours string   `yaml:"allowedHours,omitempty" json:"allowedHours,omitempty" toml:"allowedHours,omitempty"` // Hour ranges such as "0-6" or "22-2,12", inclusive (default: every hour)
+	AllowedDays  []string `yaml:"allowedDays,omitempty" json:"allowedDays,omitempty" toml:"allowedDays,omitempty"`    // Weekdays such as ["Sat", "Sun"] (default: every day)
+	Timezone     string   `yaml:"timezone,omitempty" json:"timezone,omitempty" toml:"timezone,omitempty"`             // IANA timezone the windows are in (default: local time)
+}
+
+// schedule returns the maintenance window for p: its own, or the global one.
+// A nil schedule allows deploys at any time.
+func (c Config) schedule(p Project) *Schedule {
+	if p.Schedule != nil {
+		return p.Schedule
+	}
+	return c.Schedule
+}
+
+// Validate checks that the hours, days and timezone can be parsed.
+func (s Schedule) Validate() error {
+	if _, err := parseHours(s.AllowedHours); err != nil {
+		return err
+	}
+	if _, err := parseDays(s.AllowedDays); err != nil {
+		return err
+	}
+	if _, err := s.location(); err != nil {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	return nil
+}
+
+// location returns the timezone the windows are in, local time by default.
+func (s Schedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+// allows reports whether t falls inside an allowed window. The schedule is
+// assumed to be valid.
+func (s Schedule) allows(t time.Time) bool {
+	loc, _ := s.location()
+	hours, _ := parseHours(s.AllowedHours)
+	days, _ := parseDays(s.AllowedDays)
+	t = t.In(loc)
+	return hours[t.Hour()] && days[t.Weekday()]
+}
+
+// next returns the start of the first allowed hour after t, or the zero time
+// if the schedule allows nothing.
+func (s Schedule) next(t time.Time) time.Time {
+	loc, _ := s.location()
+	t = t.In(loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	for i := 1; i <= 8*24; i++ {
+		if c := t.Add(time.Duration(i) * time.Hour); s.allows(c) {
+			return c
+		}
+	}
+	return time.Time{}
+}
+
+// parseHours parses a comma-separated list of hours and inclusive hour
+// ranges. A range whose end is before its start wraps past midnight.
+func parseHours(spec string) ([24]bool, error) {
+	var hours [24]bool
+	if strings.TrimSpace(spec) == "" {
+		for h := range hours {
+			hours[h] = true
+		}
+		return hours, nil
+	}
+	parseHour := func(s string) (int, error) {
+		h, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || h < 0 || h > 23 {
+			return 0, fmt.Errorf("invalid hour %q in allowedHours (must be 0-23)", s)
+		}
+		return h, nil
+	}
+	for part := range strings.SplitSeq(spec, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := parseHour(from)
+		if err != nil {
+			return hours, err
+		}
+		end := start
+		if isRange {
+			if end, err = parseHour(to); err != nil {
+				return hours, err
+			}
+		}
+		for h := start; ; h = (h + 1) % 24 {
+			hours[h] = true
+			if h == end {
+				break
+			}
+		}
+	}
+	return hours, nil
+}
+
+// parseDays parses weekday names, either abbreviated ("Sat") or in full
+// ("Saturday"), ignoring case.
+func parseDays(names []string) ([7]bool, error) {
+	var days [7]bool
+	if len(names) == 0 {
+		for d := range days {
+			days[d] = true
+		}
+		return days, nil
+	}
+	for _, name := range names {
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(name, d.String()) || strings.EqualFold(name, d.String()[:3]) {
+				days[d] = true
+				found = true
+			}
+		}
+		if !found {
+			return days, fmt.Errorf("invalid day %q in allowedDays", name)
+		}
+	}
+	return days, nil
+}
+
+// deferUpdate is called instead of deploying when p is checked outside its
+// maintenance window. It still fetches, so a waiting deploy is noticed and
+// recorded in the state file, but leaves the checkout and service as they
+// are.
+func deferUpdate(ctx context.Context, config Config, p Project, clone bool, log *slog.Logger) error {
+	next := config.schedule(p).next(time.Now())
+	log = log.With("nextWindow", next.Format(time.RFC3339))
+
+	var pending string
+	switch {
+	case clone:
+		pending = "clone"
+	case p.Type == "image":
+		current, _ := getImageDigest(ctx, p.Image)
+		remote, err := getRemoteImageDigest(ctx, p.Image)
+		if err != nil || remote == "" {
+			log.Warn("Could not check remote digest", "image", p.Image, "error", err)
+			return nil
+		}
+		if strings.HasSuffix(current, remote) {
+			log.Info("Image already up to date", "image", p.Image)
+			recordPending(p.Name, "")
+			return nil
+		}
+		pending = remote
+	default:
+		if err := gitCheckRemote(ctx, p); err != nil {
+			log.Error("Git remote not found", "error", err)
+			return err
+		}
+		head, fetched, err := incomingCommit(ctx, config, p, log)
+		if err != nil {
+			return err
+		}
+		if fetched == head {
+			log.Info("No new commits", "commit", fetched)
+			recordPending(p.Name, "")
+			return nil
+		}
+		pending = fetched
+	}
+
+	log.Info("Outside maintenance window, deploy deferred", "pending", pending)
+	recordPending(p.Name, pending)
+	return nil
+}
@@ -0,0 +1,121 @@
+package updatectl
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// secretRefPattern matches the ${env:NAME} and ${file:/path} references that
+// secret settings may contain in place of the secret itself.
+var secretRefPattern = regexp.MustCompile(`\$\{(env|file):([^}]*)\}`)
+
+// resolveSecretRefs replaces every secret reference in s with the value it
+// points to: an environment variable, or a file's contents without trailing
+// newlines.
+func resolveSecretRefs(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var resolveErr error
+	resolved := secretRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		m := secretRefPattern.FindStringSubmatch(ref)
+		kind, target := m[1], strings.TrimSpace(m[2])
+		switch {
+		case target == "":
+			resolveErr = fmt.Errorf("%s names no %s", ref, kind)
+		case kind == "env":
+			value, ok := os.LookupEnv(target)
+			if !ok {
+				resolveErr = fmt.Errorf("environment variable %s is not set", target)
+			}
+			return value
+		default:
+			data, err := os.ReadFile(target)
+			if err != nil {
+				resolveErr = fmt.Errorf("reading secret: %w", err)
+				return ""
+			}
+			return strings.TrimRight(string(data), "\r\n")
+		}
+		return ""
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+// withSecrets returns a copy of c with defaults applied and the secret
+// references in its secret settings resolved: each project's token, sshKey,
+// webhookSecret, repo, env values and requireCIStatus.token, the notify
+// URLs, proxy and api.token. c itself, which may be written back to the
+// config file, keeps the references. A reference that can't be resolved is
+// reported and its setting left empty.
+func (c Config) withSecrets() (Config, []error) {
+	var problems []error
+	resolve := func(label, field string, value *string) {
+		resolved, err := resolveSecretRefs(*value)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s%s: %w", label, field, err))
+		}
+		*value = resolved
+	}
+
+	resolve("", "proxy", &c.Proxy)
+	if c.API != nil {
+		api := *c.API
+		resolve("", "api.token", &api.Token)
+		c.API = &api
+	}
+	resolve("", "notify.webhook", &c.Notify.Webhook)
+	c.Notify.Notifiers = append([]NotifierConfig(nil), c.Notify.Notifiers...)
+	for i := range c.Notify.Notifiers {
+		resolve("", fmt.Sprintf("notify.notifiers[%d].url", i), &c.Notify.Notifiers[i].URL)
+	}
+
+	c.Projects = append([]Project(nil), c.resolvedProjects()...)
+	c.Defaults = nil
+	for i := range c.Projects {
+		p := &c.Projects[i]
+		label := fmt.Sprintf("project %d: ", i+1)
+		if p.Name != "" {
+			label = fmt.Sprintf("project %q: ", p.Name)
+		}
+		resolve(label, "token", &p.Token)
+		resolve(label, "sshKey", &p.SSHKey)
+		resolve(label, "webhookSecret", &p.WebhookSecret)
+		resolve(label, "repo", &p.Repo)
+		if p.Env != nil {
+			p.Env = maps.Clone(p.Env)
+			for key, value := range p.Env {
+				resolve(label, "env."+key, &value)
+				p.Env[key] = value
+			}
+		}
+		if p.RequireCIStatus != nil {
+			ci := *p.RequireCIStatus
+			resolve(label, "requireCIStatus.token", &ci.Token)
+			p.RequireCIStatus = &ci
+		}
+	}
+
+	return c, problems
+}
+
+// redactedURL returns raw without any password, for logs and error messages.
+// HTTP(S) URLs lose the username too, since a token is often passed as one.
+func redactedURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.User != nil {
+		if u.Scheme == "http" || u.Scheme == "https" {
+			u.User = nil
+		} else {
+			u.User = url.User(u.User.Username())
+		}
+		return u.String()
+	}
+	return raw
+}
@@ -0,0 +1,63 @@
+package updatectl
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ShellOverride, when set, replaces Config.Shell. The updatectl command sets
+// it from --shell.
+var ShellOverride string
+
+// ShellNone runs commands directly, split on whitespace, without a shell.
+const ShellNone = "none"
+
+// EffectiveShell returns the shell used for build commands and hooks: the --shell
+// flag, then the config, then bash (cmd on Windows).
+func (c Config) EffectiveShell() string {
+	switch {
+	case ShellOverride != "":
+		return ShellOverride
+	case c.Shell != "":
+		return c.Shell
+	case runtime.GOOS == "windows":
+		return "cmd"
+	}
+	return "bash"
+}
+
+// shellCommand returns a command that runs command through shell.
+func shellCommand(shell, command string) (Command, error) {
+	if shell == ShellNone {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return Command{}, fmt.Errorf("empty command")
+		}
+		return Command{Name: fields[0], Args: fields[1:]}, nil
+	}
+
+	// Each shell family spells "run this string" differently.
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(shell), filepath.Ext(shell)))
+	switch name {
+	case "cmd":
+		return Command{Name: shell, Args: []string{"/C", command}}, nil
+	case "powershell", "pwsh":
+		return Command{Name: shell, Args: []string{"-NoProfile", "-Command", command}}, nil
+	}
+	return Command{Name: shell, Args: []string{"-c", command}}, nil
+}
+
+// WarnIfShellMissing logs a warning if the configured shell can't be found,
+// so a typo shows up at startup rather than as a failed build later.
+func WarnIfShellMissing(config Config) {
+	shell := config.EffectiveShell()
+	if shell == ShellNone {
+		return
+	}
+	if _, err := exec.LookPath(shell); err != nil {
+		Logger.Warn("Shell for build commands not found on PATH, builds will fail", "shell", shell, "error", err)
+	}
+}
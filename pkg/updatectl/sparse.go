@@ -0,0 +1,103 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// sparsePaths returns p's SparsePaths as git's cone mode lists them: slash
+// separated, without leading or trailing slashes, sorted.
+func (p Project) sparsePaths() []string {
+	paths := make([]string, 0, len(p.SparsePaths))
+	for _, dir := range p.SparsePaths {
+		paths = append(paths, path.Clean(strings.Trim(filepath.ToSlash(dir), "/")))
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+// inSparseCheckout reports whether rel, relative to p's path, is checked out
+// given p's SparsePaths: it is inside one of them, or is a file at the top of
+// the repository, which cone mode always includes. Without SparsePaths
+// everything is.
+func (p Project) inSparseCheckout(rel string, isDir bool) bool {
+	if len(p.SparsePaths) == 0 {
+		return true
+	}
+	rel = path.Clean(filepath.ToSlash(rel))
+	if rel == "." || (!isDir && !strings.Contains(rel, "/")) {
+		return true
+	}
+	for _, dir := range p.sparsePaths() {
+		if rel == dir || strings.HasPrefix(rel, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// gitSetSparse limits p's checkout to its SparsePaths, fetching the files
+// they need from the remote in a partial clone.
+func gitSetSparse(ctx context.Context, p Project) ([]byte, error) {
+	args := append([]string{"-C", p.Path, "sparse-checkout", "set", "--cone", "--"}, p.sparsePaths()...)
+	return runGitAuthCombined(ctx, p, args...)
+}
+
+// syncSparseCheckout makes an existing checkout's sparse-checkout match p's
+// SparsePaths, for checkouts cloned before they were set or changed. A
+// checkout whose project no longer sets SparsePaths is left as it is.
+func syncSparseCheckout(ctx context.Context, p Project, log *slog.Logger) error {
+	if len(p.SparsePaths) == 0 {
+		return nil
+	}
+	if out, err := runGit(ctx, "-C", p.Path, "config", "--bool", "core.sparseCheckoutCone"); err == nil && strings.TrimSpace(string(out)) == "true" {
+		if out, err := runGit(ctx, "-C", p.Path, "sparse-checkout", "list"); err == nil &&
+			slices.Equal(strings.Fields(string(out)), p.sparsePaths()) {
+			return nil
+		}
+	}
+	log.Info("Updating sparse checkout", "paths", strings.Join(p.sparsePaths(), ","))
+	if output, err := gitSetSparse(ctx, p); err != nil {
+		log.Error("Git sparse-checkout failed", "error", err, "output", strings.TrimSpace(string(output)))
+		return fmt.Errorf("git sparse-checkout failed: %w", err)
+	}
+	return nil
+}
+
+// validateSparsePaths reports SparsePaths entries that aren't directories
+// inside the repository, and files the build or restart needs that the
+// sparse checkout leaves out.
+func validateSparsePaths(label string, p Project) []error {
+	if len(p.SparsePaths) == 0 {
+		return nil
+	}
+	var problems []error
+	if p.Type == "image" {
+		problems = append(problems, fmt.Errorf("%s: sparsePaths is not supported for image type", label))
+	}
+	for _, dir := range p.SparsePaths {
+		clean := path.Clean(strings.Trim(filepath.ToSlash(dir), "/"))
+		if strings.TrimSpace(dir) == "" || filepath.IsAbs(dir) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(clean, "-") {
+			problems = append(problems, fmt.Errorf("%s: sparsePaths entry %q must be a directory inside the repository", label, dir))
+		}
+	}
+	for _, file := range []struct {
+		field, rel string
+		isDir      bool
+	}{
+		{"buildDir", p.BuildDir, true},
+		{"composeFile", p.ComposeFile, false},
+		{"manifest", p.Manifest, false},
+		{"envFile", p.EnvFile, false},
+	} {
+		if file.rel != "" && !filepath.IsAbs(file.rel) && !p.inSparseCheckout(file.rel, file.isDir) {
+			problems = append(problems, fmt.Errorf("%s: %s %q is outside sparsePaths, so it won't be checked out", label, file.field, file.rel))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,213 @@
+package updatectl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ProjectState is what updatectl remembers about a project between runs.
+type ProjectState struct {
+	LastUpdate  time.Time `json:"lastUpdate"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt,omitzero"`
+	// RolledBackFrom is the commit that failed to deploy and was rolled
+	// back. It is not deployed again until a newer commit arrives.
+	RolledBackFrom string `json:"rolledBackFrom,omitempty"`
+	// Health is the result of the most recent health check, "healthy" or
+	// "unhealthy". The reason for a failure is kept in LastError.
+	Health   string    `json:"health,omitempty"`
+	HealthAt time.Time `json:"healthAt,omitzero"`
+	// Pending is the commit (or image digest, or "clone") found outside the
+	// maintenance window and waiting to be deployed.
+	Pending      string    `json:"pending,omitempty"`
+	PendingSince time.Time `json:"pendingSince,omitzero"`
+	// PinnedRef is the ref a project pinned with ref was last resolved
+	// from, and PinnedCommit the commit it resolved to. The project stays
+	// on that commit until the config names a different ref.
+	PinnedRef    string `json:"pinnedRef,omitempty"`
+	PinnedCommit string `json:"pinnedCommit,omitempty"`
+}
+
+// State is persisted as updatectl-state.json in the StateDir.
+type State struct {
+	Projects map[string]ProjectState `json:"projects"`
+}
+
+const stateFileName = "updatectl-state.json"
+
+// StatePath returns the location of the state file, in the StateDir.
+func StatePath() string {
+	return filepath.Join(StateDir(), stateFileName)
+}
+
+// stateLockPath returns the lock file that serializes changes to the state
+// file between processes.
+func stateLockPath() string {
+	return StatePath() + ".lock"
+}
+
+// stateLockTimeout bounds how long a state change waits for another process
+// to finish its own.
+const stateLockTimeout = 10 * time.Second
+
+var errStateLocked = errors.New("state file is locked by another updatectl process")
+
+// LoadState reads the state file, returning an empty state if it does not exist yet.
+// The file is only ever replaced whole, so it can be read at any time.
+func LoadState() (State, error) {
+	state := State{Projects: make(map[string]ProjectState)}
+
+	data, err := os.ReadFile(StatePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse state %s: %w", StatePath(), err)
+	}
+	if state.Projects == nil {
+		state.Projects = make(map[string]ProjectState)
+	}
+	return state, nil
+}
+
+func saveState(state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(StatePath(), data, 0644)
+}
+
+// stateMu serializes read-modify-write cycles on the state file between
+// concurrently updating projects. Other processes are kept out by the lock
+// on stateLockPath.
+var stateMu sync.Mutex
+
+// RecordState stores ps as the state of the named project. It may be called
+// from any number of goroutines and processes at once.
+func RecordState(name string, ps ProjectState) error {
+	return changeState(name, func(stored *ProjectState) { *stored = ps })
+}
+
+// recordUpdate stores the time of a successful update for the named project
+// and clears any previously recorded failure.
+func recordUpdate(name string) {
+	modifyState(name, func(ps *ProjectState) {
+		ps.LastUpdate = time.Now()
+		ps.LastError = ""
+		ps.LastErrorAt = time.Time{}
+		ps.RolledBackFrom = ""
+		ps.Pending = ""
+		ps.PendingSince = time.Time{}
+	})
+}
+
+// recordPending stores a deploy deferred until the maintenance window, or
+// clears it if pending is empty. PendingSince keeps the time the first
+// deferred deploy was seen.
+func recordPending(name, pending string) {
+	modifyState(name, func(ps *ProjectState) {
+		if pending == "" {
+			ps.Pending = ""
+			ps.PendingSince = time.Time{}
+			return
+		}
+		if ps.Pending == "" {
+			ps.PendingSince = time.Now()
+		}
+		ps.Pending = pending
+	})
+}
+
+// recordPin stores the commit that ref resolved to for the named project.
+func recordPin(name, ref, commit string) {
+	modifyState(name, func(ps *ProjectState) {
+		ps.PinnedRef = ref
+		ps.PinnedCommit = commit
+	})
+}
+
+// recordFailure stores the most recent failure for the named project.
+func recordFailure(name string, failure error) {
+	modifyState(name, func(ps *ProjectState) {
+		ps.LastError = failure.Error()
+		ps.LastErrorAt = time.Now()
+	})
+}
+
+// recordRollback stores a failed deploy of commit that was rolled back.
+func recordRollback(name, commit string, failure error) {
+	modifyState(name, func(ps *ProjectState) {
+		ps.LastError = failure.Error()
+		ps.LastErrorAt = time.Now()
+		ps.RolledBackFrom = commit
+	})
+}
+
+// recordHealth stores the outcome of a health check for the named project.
+func recordHealth(name string, result error) {
+	modifyState(name, func(ps *ProjectState) {
+		ps.Health = "healthy"
+		if result != nil {
+			ps.Health = "unhealthy"
+		}
+		ps.HealthAt = time.Now()
+	})
+}
+
+// projectState returns the stored state of the named project.
+func projectState(name string) ProjectState {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	state, err := LoadState()
+	if err != nil {
+		Logger.Warn("Failed to load state", "error", err)
+	}
+	return state.Projects[name]
+}
+
+// modifyState applies fn to the stored state of the named project. Failures
+// are only logged, so a read-only state directory never fails a deploy.
+func modifyState(name string, fn func(ps *ProjectState)) {
+	if err := changeState(name, fn); err != nil {
+		Logger.Warn("Failed to save state", "error", err)
+	}
+}
+
+// changeState applies fn to the stored state of the named project, holding
+// both the in-process and the cross-process lock from reading the file until
+// the changed copy has replaced it.
+func changeState(name string, fn func(ps *ProjectState)) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	// The state directory may not exist yet, as when it has just moved to
+	// the user cache directory.
+	if err := os.MkdirAll(StateDir(), 0755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(context.Background(), stateLockPath(), stateLockTimeout, errStateLocked)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	ps := state.Projects[name]
+	fn(&ps)
+	state.Projects[name] = ps
+	return saveState(state)
+}
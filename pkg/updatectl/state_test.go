@@ -0,0 +1,98 @@
+package updatectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stateHelperEnv makes the test binary, run again by TestRecordStateConcurrent,
+// record state from a second process instead of running tests.
+const stateHelperEnv = "UPDATECTL_TEST_STATE_HELPER"
+
+const stateHelperProjects = 50
+
+func TestRecordStateHelperProcess(t *testing.T) {
+	if os.Getenv(stateHelperEnv) == "" {
+		return
+	}
+	ConfigPath = os.Getenv(stateHelperEnv)
+	for i := range stateHelperProjects {
+		if err := RecordState(fmt.Sprintf("other-%d", i), ProjectState{Pending: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecordStateConcurrent(t *testing.T) {
+	useTempConfig(t)
+
+	// Another updatectl process, such as build --pull next to the daemon,
+	// changes the same state file at the same time.
+	helper := exec.Command(os.Args[0], "-test.run=^TestRecordStateHelperProcess$")
+	helper.Env = append(os.Environ(), stateHelperEnv+"="+ConfigPath)
+	helper.Stdout, helper.Stderr = os.Stderr, os.Stderr
+	if err := helper.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines, projects = 8, 10
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range projects {
+				name := fmt.Sprintf("p%d-%d", g, i)
+				if err := RecordState(name, ProjectState{LastUpdate: time.Unix(int64(i), 0)}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := helper.Wait(); err != nil {
+		t.Fatalf("helper process: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(StateDir(), stateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("state file is not valid JSON: %v\n%s", err, data)
+	}
+	for g := range goroutines {
+		for i := range projects {
+			if name := fmt.Sprintf("p%d-%d", g, i); state.Projects[name].LastUpdate.Unix() != int64(i) {
+				t.Errorf("%s missing from the state file", name)
+			}
+		}
+	}
+	for i := range stateHelperProjects {
+		if name := fmt.Sprintf("other-%d", i); state.Projects[name].Pending != strconv.Itoa(i) {
+			t.Errorf("%s, recorded by the other process, missing from the state file", name)
+		}
+	}
+}
+
+func TestRecordStateCreatesStateDir(t *testing.T) {
+	// No lock has been taken yet to create the state directory.
+	old := ConfigPath
+	ConfigPath = filepath.Join(t.TempDir(), "new", "updatectl.yaml")
+	t.Cleanup(func() { ConfigPath = old })
+
+	if err := RecordState("app", ProjectState{Pending: "abc123"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := projectState("app").Pending; got != "abc123" {
+		t.Errorf("Pending = %q, want abc123", got)
+	}
+}
@@ -0,0 +1,64 @@
+package updatectl
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// stateDirs caches StateDir's choice for each config directory, so the
+// directory is probed and the fallback logged only once.
+var (
+	stateDirsMu sync.Mutex
+	stateDirs   = make(map[string]string)
+)
+
+// StateDir returns the directory holding the state file, deploy history,
+// locks, build logs, PID file and log file: the config file's directory.
+// When that directory can't be written to and holds no state file yet, as
+// on systems where /etc is read-only, they go to updatectl/ in the user's
+// cache directory (os.UserCacheDir) instead, and a warning says where.
+func StateDir() string {
+	configDir := filepath.Dir(ResolveConfigPath())
+	stateDirsMu.Lock()
+	defer stateDirsMu.Unlock()
+	if dir, ok := stateDirs[configDir]; ok {
+		return dir
+	}
+
+	dir := configDir
+	// A state file left by an earlier run, or by root for a user who only
+	// reads it with updatectl status, keeps state in the config directory.
+	if !dirWritable(configDir) && !fileExists(filepath.Join(configDir, stateFileName)) {
+		if cacheDir, err := os.UserCacheDir(); err != nil {
+			Logger.Warn("Config directory is not writable and there is no user cache directory to keep state in", "configDir", configDir, "error", err)
+		} else {
+			dir = filepath.Join(cacheDir, "updatectl")
+			Logger.Warn("Config directory is not writable, keeping state and logs in the user cache directory", "configDir", configDir, "stateDir", dir)
+		}
+	}
+	stateDirs[configDir] = dir
+	return dir
+}
+
+// dirWritable reports whether a file can be created in dir, or in its
+// nearest existing parent if dir doesn't exist yet.
+func dirWritable(dir string) bool {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".updatectl-probe-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
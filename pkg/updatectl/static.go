@@ -0,0 +1,190 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// staticReleasesKept is how many published releases of a static project are
+// kept next to its deploy path, counting the live one.
+const staticReleasesKept = 3
+
+// staticReleaseFormat names release directories so that they sort by age.
+const staticReleaseFormat = "20060102T150405.000000000"
+
+// staticSource returns the directory a static project publishes: OutputDir,
+// resolved relative to Path, or the whole checkout.
+func (p Project) staticSource() string {
+	switch {
+	case p.OutputDir == "":
+		return p.Path
+	case filepath.IsAbs(p.OutputDir):
+		return p.OutputDir
+	}
+	return filepath.Join(p.Path, p.OutputDir)
+}
+
+// publishStatic copies p's output into a new release directory in
+// <deployPath>.releases and then points DeployPath at it by renaming a
+// symlink over it, so the web server never serves a half-copied site. Only
+// the last few releases are kept.
+func publishStatic(ctx context.Context, p Project, log *slog.Logger, out io.Writer) error {
+	src := p.staticSource()
+	if info, err := os.Stat(src); err != nil || !info.IsDir() {
+		return fmt.Errorf("output directory %s not found", src)
+	}
+	deployPath := filepath.Clean(p.DeployPath)
+	if info, err := os.Lstat(deployPath); err == nil && info.Mode()&fs.ModeSymlink == 0 {
+		return fmt.Errorf("%s exists and is not a symlink; move it out of the way so it can be replaced atomically", deployPath)
+	}
+
+	releases := deployPath + ".releases"
+	if err := os.MkdirAll(releases, 0755); err != nil {
+		return fmt.Errorf("failed to create releases directory: %w", err)
+	}
+	release := filepath.Join(releases, time.Now().UTC().Format(staticReleaseFormat))
+	current, _ := os.Readlink(deployPath)
+
+	log.Info("Publishing static site", "from", src, "deployPath", deployPath)
+	var err error
+	rsync, lookErr := exec.LookPath("rsync")
+	switch {
+	case p.UsesRsync() && lookErr == nil:
+		err = rsyncTree(ctx, rsync, src, release, current, out)
+	case p.UsesRsync():
+		log.Warn("rsync not found on PATH, copying instead")
+		fallthrough
+	default:
+		err = copyTree(ctx, src, release)
+	}
+	if err != nil {
+		os.RemoveAll(release)
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	next := filepath.Join(filepath.Dir(deployPath), "."+filepath.Base(deployPath)+".next")
+	os.Remove(next)
+	if err := os.Symlink(release, next); err != nil {
+		os.RemoveAll(release)
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	if err := os.Rename(next, deployPath); err != nil {
+		os.Remove(next)
+		os.RemoveAll(release)
+		return fmt.Errorf("failed to switch %s to the new release: %w", deployPath, err)
+	}
+	log.Info("Published static site", "deployPath", deployPath, "release", release)
+	pruneStaticReleases(releases, release, log)
+	return nil
+}
+
+// rsyncTree copies src into the new directory dst with rsync. Files that are
+// unchanged since the release at linkDest are hard-linked instead of copied.
+func rsyncTree(ctx context.Context, rsync, src, dst, linkDest string, out io.Writer) error {
+	args := []string{"-a", "--delete", "--exclude=/.git"}
+	if linkDest != "" {
+		args = append(args, "--link-dest="+linkDest)
+	}
+	_, err := runCommand(ctx, Command{Name: rsync, Args: append(args, src+string(filepath.Separator), dst), Stdout: out, Stderr: out})
+	return err
+}
+
+// copyTree copies the directory src to dst, which must not exist yet,
+// keeping file modes and symlinks. A .git directory at the top of src is
+// left out.
+func copyTree(ctx context.Context, src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == ".git" {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		// Sockets, devices and the like have no place on a web root.
+		return nil
+	})
+}
+
+// copyFile copies the regular file src to dst with mode perm.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// pruneStaticReleases removes all but the newest staticReleasesKept release
+// directories in dir, never the live one. Failures are only logged.
+func pruneStaticReleases(dir, live string, log *slog.Logger) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Warn("Failed to list old releases", "dir", dir, "error", err)
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if _, err := time.Parse(staticReleaseFormat, e.Name()); e.IsDir() && err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	for len(names) > staticReleasesKept {
+		old := filepath.Join(dir, names[0])
+		names = names[1:]
+		if old == live {
+			continue
+		}
+		if err := os.RemoveAll(old); err != nil {
+			log.Warn("Failed to remove old release", "release", old, "error", err)
+		}
+	}
+}
+
+// insideDir reports whether path is dir or somewhere below it.
+func insideDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,74 @@
+package updatectl
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// ProjectStatus is a project's checkout and recorded state, as shown by
+// updatectl status and the API's GET /projects.
+type ProjectStatus struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Commit      string     `json:"commit,omitempty"`
+	Branch      string     `json:"branch,omitempty"`
+	Dirty       bool       `json:"dirty"`
+	LastUpdate  *time.Time `json:"lastUpdate,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+	RolledBack  bool       `json:"rolledBack"`
+	Pending     string     `json:"pending,omitempty"`
+	PendingAt   *time.Time `json:"pendingSince,omitempty"`
+	Health      string     `json:"health,omitempty"`
+	HealthAt    *time.Time `json:"healthAt,omitempty"`
+}
+
+// ReadProjectStatus returns p's status from state and, for projects with a
+// checkout, from git: the commit and branch checked out and whether there
+// are local changes.
+func ReadProjectStatus(ctx context.Context, p Project, state State) ProjectStatus {
+	s := ProjectStatus{Name: p.Name, Type: p.Type}
+	if ps, ok := state.Projects[p.Name]; ok {
+		if !ps.LastUpdate.IsZero() {
+			lastUpdate := ps.LastUpdate
+			s.LastUpdate = &lastUpdate
+		}
+		if ps.LastError != "" {
+			lastErrorAt := ps.LastErrorAt
+			s.LastError = ps.LastError
+			s.LastErrorAt = &lastErrorAt
+		}
+		s.RolledBack = ps.RolledBackFrom != ""
+		if ps.Pending != "" {
+			pendingSince := ps.PendingSince
+			s.Pending = ps.Pending
+			s.PendingAt = &pendingSince
+		}
+		if ps.Health != "" {
+			healthAt := ps.HealthAt
+			s.Health = ps.Health
+			s.HealthAt = &healthAt
+		}
+	}
+	if p.Type == "image" || p.Path == "" {
+		return s
+	}
+
+	if out, err := runGit(ctx, "-C", p.Path, "rev-parse", "HEAD"); err == nil {
+		s.Commit = strings.TrimSpace(string(out))
+	}
+	if out, err := runGit(ctx, "-C", p.Path, "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		s.Branch = strings.TrimSpace(string(out))
+	}
+	// A detached checkout, such as one deploying tags, shows its tag instead.
+	if s.Branch == "HEAD" {
+		if out, err := runGit(ctx, "-C", p.Path, "describe", "--tags", "--exact-match", "HEAD"); err == nil {
+			s.Branch = strings.TrimSpace(string(out))
+		}
+	}
+	if out, err := runGit(ctx, "-C", p.Path, "status", "--porcelain"); err == nil {
+		s.Dirty = strings.TrimSpace(string(out)) != ""
+	}
+	return s
+}
@@ -0,0 +1,221 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed semantic version tag such as v1.4.0 or 2.0.0-rc.1.
+// Build metadata is accepted but plays no part in ordering.
+type semver struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+// parseSemver parses tag as a semantic version with an optional leading v.
+// It reports false for tags that aren't versions.
+func parseSemver(tag string) (semver, bool) {
+	s := strings.TrimPrefix(tag, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || part[0] == '+' || (len(part) > 1 && part[0] == '0') {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+	v := semver{major: nums[0], minor: nums[1], patch: nums[2]}
+	if hasPre {
+		v.prerelease = strings.Split(pre, ".")
+		for _, id := range v.prerelease {
+			if id == "" {
+				return semver{}, false
+			}
+		}
+	}
+	return v, true
+}
+
+// compare returns -1, 0 or 1 as v is older than, the same as or newer than
+// w, following the semver precedence rules: a prerelease sorts before its
+// release, and prerelease identifiers compare numerically when both are
+// numbers.
+func (v semver) compare(w semver) int {
+	for _, d := range []int{v.major - w.major, v.minor - w.minor, v.patch - w.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(v.prerelease) == 0 && len(w.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(w.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(w.prerelease); i++ {
+		a, b := v.prerelease[i], w.prerelease[i]
+		an, aErr := strconv.Atoi(a)
+		bn, bErr := strconv.Atoi(b)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return sign(an - bn)
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case a != b:
+			return strings.Compare(a, b)
+		}
+	}
+	return sign(len(v.prerelease) - len(w.prerelease))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// tagPattern returns the glob the tags p deploys must match, "*" if unset.
+func (p Project) tagPattern() string {
+	if p.TagPattern != "" {
+		return p.TagPattern
+	}
+	return "*"
+}
+
+// highestTag returns the highest semver tag in tags that matches pattern, or
+// "" if there is none.
+func highestTag(tags []string, pattern string) string {
+	var best string
+	var bestVersion semver
+	for _, tag := range tags {
+		if ok, _ := path.Match(pattern, tag); !ok {
+			continue
+		}
+		v, ok := parseSemver(tag)
+		if !ok {
+			continue
+		}
+		if best == "" || v.compare(bestVersion) > 0 {
+			best, bestVersion = tag, v
+		}
+	}
+	return best
+}
+
+// gitLatestTag returns the highest semver tag on p's remote matching p's tag
+// pattern and the commit it points at, or empty strings if no tag matches.
+// It uses ls-remote, so nothing in the local checkout is modified.
+func gitLatestTag(ctx context.Context, p Project) (string, string, error) {
+	remote := projectRemote(p)
+	out, err := runGitAuth(ctx, p, "-C", p.Path, "ls-remote", "--tags", remote)
+	if err != nil {
+		return "", "", fmt.Errorf("git ls-remote: %w", err)
+	}
+	// Annotated tags are listed twice: the tag object, then the commit it
+	// points at with ^{} appended. The commit is what gets checked out.
+	commits := map[string]string{}
+	var tags []string
+	for line := range strings.Lines(string(out)) {
+		hash, ref, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+		name := strings.TrimPrefix(ref, "refs/tags/")
+		if peeled, ok := strings.CutSuffix(name, "^{}"); ok {
+			commits[peeled] = hash
+			continue
+		}
+		if _, ok := commits[name]; !ok {
+			commits[name] = hash
+		}
+		tags = append(tags, name)
+	}
+	tag := highestTag(tags, p.tagPattern())
+	return tag, commits[tag], nil
+}
+
+// gitDeployedTag returns the highest tag matching p's tag pattern that points
+// at the commit checked out in p's checkout, or "" if there is none.
+func gitDeployedTag(ctx context.Context, p Project) string {
+	out, err := runGit(ctx, "-C", p.Path, "tag", "--points-at", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return highestTag(strings.Fields(string(out)), p.tagPattern())
+}
+
+// gitNewTag returns the tag p should deploy next and its commit: the highest
+// matching tag on the remote, provided it is newer than the tag currently
+// deployed. Empty strings mean there is nothing new to deploy, including when
+// no tag matches at all. A checkout that isn't on a matching tag, such as one
+// that tracked a branch before, takes the latest tag whatever it is.
+func gitNewTag(ctx context.Context, p Project) (string, string, error) {
+	tag, commit, err := gitLatestTag(ctx, p)
+	if err != nil || tag == "" || commit == gitHead(ctx, p.Path) {
+		return "", "", err
+	}
+	if deployed := gitDeployedTag(ctx, p); deployed != "" {
+		latest, _ := parseSemver(tag)
+		current, _ := parseSemver(deployed)
+		if latest.compare(current) <= 0 {
+			return "", "", nil
+		}
+	}
+	return tag, commit, nil
+}
+
+// gitCheckoutTag fetches tag from p's remote, keeping the fetch shallow if p
+// has a depth, and checks it out as a detached HEAD. The combined output of
+// every step that ran is returned.
+func gitCheckoutTag(ctx context.Context, p Project, tag string) ([]byte, error) {
+	ref := "refs/tags/" + tag
+	fetch := []string{"fetch", "--force"}
+	if p.Depth > 0 {
+		fetch = append(fetch, "--depth", strconv.Itoa(p.Depth))
+	}
+	return gitSteps(ctx, p, [][]string{
+		append(fetch, projectRemote(p), ref+":"+ref),
+		{"checkout", "--detach", ref},
+	})
+}
+
+// checkoutNewTag checks out the tag gitNewTag picks for p, retrying transient
+// failures. It returns the tag, or "" if there was nothing new to deploy, and
+// the output of the git commands that ran.
+func checkoutNewTag(ctx context.Context, config Config, p Project, log *slog.Logger) (string, []byte, error) {
+	var tag string
+	output, err := retryGit(ctx, config, p, log, func() (output []byte, err error) {
+		if tag, _, err = gitNewTag(ctx, p); err != nil || tag == "" {
+			return nil, err
+		}
+		return gitCheckoutTag(ctx, p, tag)
+	})
+	if err != nil {
+		log.Error("Git tag checkout failed", "tag", tag, "error", err, "output", strings.TrimSpace(string(output)))
+		return "", output, err
+	}
+	if tag != "" {
+		log.Info("Deploying tag", "tag", tag)
+	}
+	return tag, output, nil
+}
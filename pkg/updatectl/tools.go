@@ -0,0 +1,84 @@
+package updatectl
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Tool is an external program updatectl runs to deploy some projects.
+type Tool struct {
+	Name    string   // Name shown to the user, e.g. "docker compose"
+	Command []string // Command that prints the version; only looked up on PATH if it has no arguments
+}
+
+var (
+	toolGit       = Tool{"git", []string{"git", "--version"}}
+	toolDocker    = Tool{"docker", []string{"docker", "--version"}}
+	toolCompose   = Tool{"docker compose", []string{"docker", "compose", "version"}}
+	toolPM2       = Tool{"pm2", []string{"pm2", "--version"}}
+	toolSystemctl = Tool{"systemctl", []string{"systemctl", "--version"}}
+	toolKubectl   = Tool{"kubectl", []string{"kubectl", "version", "--client"}}
+)
+
+// toolTimeout bounds how long a tool may take to print its version.
+const toolTimeout = 10 * time.Second
+
+// RequiredTools returns the external programs needed to deploy p under
+// config, in the order they are used: git, the shell for its commands and
+// the program that restarts it.
+func RequiredTools(config Config, p Project) []Tool {
+	var tools []Tool
+	if p.Type != "image" {
+		tools = append(tools, toolGit)
+	}
+	if shell := config.EffectiveShell(); shell != ShellNone && (len(p.BuildCommand) > 0 || p.PreUpdate != "" || p.PostUpdate != "") {
+		// Shells such as dash have no version flag, so they are only
+		// looked up.
+		tools = append(tools, Tool{shell, []string{shell}})
+	}
+	switch p.Type {
+	case "pm2":
+		tools = append(tools, toolPM2)
+	case "docker", "docker-compose":
+		tools = append(tools, toolCompose)
+	case "image":
+		tools = append(tools, toolDocker)
+	case "systemd":
+		tools = append(tools, toolSystemctl)
+	case "kubernetes":
+		tools = append(tools, toolKubectl)
+	}
+	if p.PrunesImages() && p.Type != "image" {
+		tools = append(tools, toolDocker)
+	}
+	return tools
+}
+
+// CheckTool reports whether t is on PATH and runs, returning the first line
+// of its version output.
+func CheckTool(ctx context.Context, t Tool) (string, error) {
+	path, err := exec.LookPath(t.Command[0])
+	if err != nil {
+		return "", fmt.Errorf("%s not found on PATH", t.Command[0])
+	}
+	if len(t.Command) == 1 {
+		return path, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, toolTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, t.Command[1:]...)
+	killProcessTreeOnCancel(cmd)
+	output, err := cmd.CombinedOutput()
+	first, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	if err != nil {
+		if first != "" {
+			return "", fmt.Errorf("%s: %w: %s", strings.Join(t.Command, " "), err, first)
+		}
+		return "", fmt.Errorf("%s: %w", strings.Join(t.Command, " "), err)
+	}
+	return strings.TrimSpace(first), nil
+}
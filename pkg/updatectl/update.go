@@ -0,0 +1,888 @@
+package updatectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BuildFailures counts failed builds since the process started.
+var BuildFailures atomic.Int64
+
+// ErrUnfinished marks projects that a cancelled cycle stopped before their
+// update completed, or never started.
+var ErrUnfinished = errors.New("did not finish")
+
+// projectError is a failure of one project in an update cycle.
+type projectError struct {
+	project string
+	err     error
+	// hushed is set for the failures of a project that keeps failing that
+	// aren't logged.
+	hushed bool
+}
+
+func (e *projectError) Error() string { return e.project + ": " + e.err.Error() }
+func (e *projectError) Unwrap() error { return e.err }
+
+// UnfinishedProjects returns the projects reported by RunCycle as not having
+// finished before its context was cancelled.
+func UnfinishedProjects(err error) []string {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, e := range joined.Unwrap() {
+		var pe *projectError
+		if errors.As(e, &pe) && errors.Is(pe.err, ErrUnfinished) {
+			names = append(names, pe.project)
+		}
+	}
+	return names
+}
+
+// ReportedErrors returns err, as returned by RunCycle, without the failures
+// of projects that keep failing that are not to be logged this cycle, or nil
+// if none are left.
+func ReportedErrors(err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return err
+	}
+	var reported []error
+	for _, e := range joined.Unwrap() {
+		var pe *projectError
+		if errors.As(e, &pe) && pe.hushed {
+			continue
+		}
+		reported = append(reported, e)
+	}
+	return errors.Join(reported...)
+}
+
+// ProjectResult is the outcome of one project in an update cycle.
+type ProjectResult struct {
+	Name string `json:"name"`
+	// Result is "updated" if a new version was deployed, "unchanged" if
+	// there was nothing to deploy or the deploy was deferred, or "failed".
+	Result string `json:"result"`
+	// From and To are the commits (or image digests) before and after the
+	// update; they are equal unless the checkout moved.
+	From     string  `json:"from,omitempty"`
+	To       string  `json:"to,omitempty"`
+	Duration float64 `json:"durationSeconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// projectVersion returns the commit checked out for p, or the digest of its
+// image, or "" if there is none yet.
+func projectVersion(ctx context.Context, p Project) string {
+	if p.Type == "image" {
+		digest, _ := getImageDigest(ctx, p.Image)
+		return digest
+	}
+	return gitHead(ctx, p.Path)
+}
+
+// UpdateProjectResult runs UpdateProject for p and describes its outcome.
+func (u *Updater) UpdateProjectResult(ctx context.Context, config Config, p Project, out io.Writer) (ProjectResult, error) {
+	ctx = u.withUpdater(ctx)
+	r := ProjectResult{Name: p.Name, From: projectVersion(ctx, p)}
+	lastUpdate := projectState(p.Name).LastUpdate
+	start := time.Now()
+	err := u.UpdateProject(ctx, config, p, out)
+	r.Duration = time.Since(start).Seconds()
+	r.To = projectVersion(ctx, p)
+	switch {
+	case err != nil:
+		r.Result = "failed"
+	case r.From != r.To, projectState(p.Name).LastUpdate.After(lastUpdate):
+		r.Result = "updated"
+	default:
+		r.Result = "unchanged"
+	}
+	return r, err
+}
+
+// RunCycle updates every project in config using up to config.concurrency()
+// workers and returns an error naming each project that failed. When running
+// in parallel, each project's output is buffered and flushed in one piece,
+// prefixed with the project name, so concurrent builds don't interleave. If
+// ctx is cancelled, projects that were interrupted or never started are
+// reported with ErrUnfinished.
+func (u *Updater) RunCycle(ctx context.Context, config Config) error {
+	_, err := u.RunCycleResults(ctx, config)
+	return err
+}
+
+// RunCycleResults is RunCycle, also returning the outcome of each project in
+// config order.
+func (u *Updater) RunCycleResults(ctx context.Context, config Config) (results []ProjectResult, err error) {
+	ctx = u.withUpdater(ctx)
+	defer func() {
+		if ctx.Err() == nil {
+			u.cycles.record(err == nil)
+		}
+	}()
+	var (
+		errMu  sync.Mutex
+		failed []error
+	)
+	results = make([]ProjectResult, len(config.Projects))
+	// recordResult reports whether the project's output is to be shown.
+	recordResult := func(i int, r ProjectResult, err error) bool {
+		p := config.Projects[i]
+		if err != nil && ctx.Err() != nil {
+			err = ErrUnfinished
+		}
+		r.Name = p.Name
+		if err != nil {
+			r.Result, r.Error = "failed", err.Error()
+		}
+		results[i] = r
+		failures := recordCheckResult(p.Name, err)
+		logged := logsFailure(failures)
+		if failures >= failureBackoffAfter && logged {
+			Logger.Warn("Project keeps failing, checking it less often", "project", p.Name, "failures", failures, "nextCheck", config.NextCheck(p, time.Now()).Format(time.DateTime))
+		}
+		if err == nil {
+			return true
+		}
+		errMu.Lock()
+		defer errMu.Unlock()
+		failed = append(failed, &projectError{project: p.Name, err: err, hushed: !logged})
+		return logged
+	}
+
+	concurrency := config.concurrency()
+	if concurrency <= 1 {
+		for i, p := range config.Projects {
+			if ctx.Err() != nil {
+				recordResult(i, ProjectResult{}, ErrUnfinished)
+				continue
+			}
+			Logger.Log(ctx, progressLevel(ctx), "Checking project", "project", p.Name)
+			// A project that keeps failing only has some of its failures
+			// logged.
+			out, show := hushOutput(p.Name, Output)
+			r, err := u.UpdateProjectResult(ctx, config, p, out)
+			show(!recordResult(i, r, err))
+		}
+		return results, errors.Join(failed...)
+	}
+
+	var wg sync.WaitGroup
+	var outMu sync.Mutex
+	sem := make(chan struct{}, concurrency)
+	for i, p := range config.Projects {
+		wg.Add(1)
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			// Shutting down: don't start any more projects.
+			<-sem
+			wg.Done()
+			recordResult(i, ProjectResult{}, ErrUnfinished)
+			continue
+		}
+		go func(i int, p Project) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Output is streamed as it happens, a line at a time, so lines
+			// from parallel projects interleave but never mix.
+			prefix := p.Name
+			if LogFormat == "json" {
+				// JSON records already carry the project field.
+				prefix = ""
+			}
+			pw := NewPrefixWriter(Output, &outMu, prefix)
+			defer pw.Flush()
+			out, show := hushOutput(p.Name, pw)
+			NewLogger(out).Log(ctx, progressLevel(ctx), "Checking project", "project", p.Name)
+			r, err := u.UpdateProjectResult(ctx, config, p, out)
+			show(!recordResult(i, r, err))
+		}(i, p)
+	}
+	wg.Wait()
+	return results, errors.Join(failed...)
+}
+
+// RunBuildCommand runs command through shell in dir. env holds extra
+// KEY=VALUE pairs added to the inherited environment for this command only.
+func RunBuildCommand(ctx context.Context, shell, command, dir string, env []string, out io.Writer) error {
+	return runCommandAs(ctx, shell, command, dir, "", env, out)
+}
+
+// runCommandAs is RunBuildCommand running the command as user, or as the
+// daemon's own user when user is empty.
+func runCommandAs(ctx context.Context, shell, command, dir, user string, env []string, out io.Writer) error {
+	cmd, err := shellCommand(shell, command)
+	if err != nil {
+		return err
+	}
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.User = user
+	cmd.Stdout = out
+	cmd.Stderr = out
+	_, err = runCommand(ctx, cmd)
+	return err
+}
+
+// undoGitUpdate puts p's checkout back on before, so a commit that may not be
+// deployed is never built. A fresh clone is removed instead, so the next check
+// clones again.
+func undoGitUpdate(ctx context.Context, p Project, clone bool, before string, log *slog.Logger) {
+	if clone {
+		if err := os.RemoveAll(p.Path); err != nil {
+			log.Error("Failed to remove clone", "error", err)
+		}
+		return
+	}
+	if output, err := gitResetHard(ctx, p.Path, before); err != nil {
+		log.Error("Git reset failed", "error", err, "output", strings.TrimSpace(string(output)))
+	}
+}
+
+// RunBuild runs p's build commands in order, stopping at the first that
+// fails, and kills the build if it exceeds the configured build timeout,
+// which covers all the steps together. The output is also saved as a build
+// log.
+func (u *Updater) RunBuild(ctx context.Context, config Config, p Project, out io.Writer) error {
+	ctx = u.withUpdater(ctx)
+	timeout := config.buildTimeout(p)
+	buildCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	env, err := projectEnv(p)
+	if err != nil {
+		return err
+	}
+	// The build directory may only appear with the commit being built, so it
+	// is checked now rather than when the config is loaded.
+	dir := p.BuildPath()
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("build directory %s not found", dir)
+	}
+
+	// A last line without a newline mustn't run into whatever is written to
+	// a line-buffered out next.
+	if f, ok := out.(interface{ Flush() error }); ok {
+		defer f.Flush()
+	}
+
+	// Output goes to the live stream and to a build log kept for later.
+	start := time.Now()
+	buildLog, logErr := createBuildLog(p, config.buildLogs())
+	if logErr != nil {
+		Logger.Warn("Build output won't be saved", "project", p.Name, "error", logErr)
+	} else {
+		out = io.MultiWriter(out, buildLog)
+	}
+
+	for i, command := range p.BuildCommand {
+		if len(p.BuildCommand) > 1 {
+			fmt.Fprintf(out, "# step %d/%d: %s\n", i+1, len(p.BuildCommand), command)
+		}
+		err = runCommandAs(buildCtx, config.EffectiveShell(), command, dir, p.RunAsUser, env, out)
+		if err != nil && ctx.Err() == nil && errors.Is(buildCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		if err != nil {
+			if len(p.BuildCommand) > 1 {
+				err = fmt.Errorf("step %d/%d (%s): %w", i+1, len(p.BuildCommand), command, err)
+			}
+			break
+		}
+	}
+	if buildLog != nil {
+		finishBuildLog(buildLog, start, err)
+	}
+	return err
+}
+
+func getImageDigest(ctx context.Context, image string) (string, error) {
+	output, err := runCommand(ctx, Command{Name: "docker", Args: []string{"inspect", "--format={{index .RepoDigests 0}}", image}})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func pullDockerImage(ctx context.Context, image string, log *slog.Logger, out io.Writer) error {
+	log.Info("Pulling Docker image", "image", image)
+	_, err := runCommand(ctx, Command{Name: "docker", Args: []string{"pull", image}, Stdout: out, Stderr: out})
+	return err
+}
+
+func restartDockerContainer(ctx context.Context, p Project, log *slog.Logger, out io.Writer) error {
+	containerName := p.ContainerName
+	if containerName == "" {
+		containerName = p.Name
+	}
+
+	// Stop and remove old container if it exists
+	log.Info("Stopping old container", "container", containerName)
+	runCommand(ctx, Command{Name: "docker", Args: []string{"stop", containerName}}) // Ignore error if container doesn't exist
+
+	runCommand(ctx, Command{Name: "docker", Args: []string{"rm", containerName}}) // Ignore error if container doesn't exist
+
+	// Build docker run command
+	args := []string{"run", "-d", "--name", containerName}
+
+	// Add port mappings if specified (can be space-separated for multiple ports)
+	if p.Port != "" {
+		portMappings := strings.Fields(p.Port)
+		for _, portMapping := range portMappings {
+			args = append(args, "-p", portMapping)
+		}
+		log.Info("Configuring ports", "ports", portMappings)
+	}
+
+	// Add environment variables
+	env, err := projectEnv(p)
+	if err != nil {
+		return err
+	}
+	if len(env) > 0 {
+		log.Info("Configuring environment variables", "count", len(env))
+	}
+	for _, kv := range env {
+		args = append(args, "-e", kv)
+	}
+
+	// Add restart policy
+	args = append(args, "--restart", "unless-stopped")
+
+	// Add image
+	args = append(args, p.Image)
+
+	log.Info("Starting new container", "container", containerName, "image", p.Image)
+	log.Debug("Running docker", "args", args)
+	_, err = runCommand(ctx, Command{Name: "docker", Args: args, Stdout: out, Stderr: out})
+	return err
+}
+func getRemoteImageDigest(ctx context.Context, image string) (string, error) {
+	output, err := runCommand(ctx, Command{Name: "docker", Args: []string{"manifest", "inspect", image}})
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(output), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, `"digest":`) {
+			// Extract digest value: "digest": "sha256:xxxxx"
+			parts := strings.Split(line, `"`)
+			if len(parts) >= 4 {
+				return parts[3], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("could not parse digest from manifest")
+}
+
+// UpdateProject checks p for updates and deploys them, returning an error if
+// the project could not be updated.
+func (u *Updater) UpdateProject(ctx context.Context, config Config, p Project, out io.Writer) (err error) {
+	ctx = withProxy(u.withUpdater(ctx), config.Proxy)
+	log := NewLogger(out).With("project", p.Name)
+	cmdOut, flush := commandWriter(log, out)
+	defer flush()
+
+	// deployed and the commit fields are set once a new version has gone
+	// live, so the outcome can be reported however the function returns.
+	var deployed bool
+	ev := notifyEvent{Project: p.Name}
+	// updating is set once a new version has been found and a deploy starts.
+	var updating bool
+	history := HistoryEvent{Project: p.Name, Trigger: "update", Time: time.Now()}
+	defer func() {
+		if updating {
+			u.metrics.recordUpdate(p.Name, err == nil)
+			history.Duration = time.Since(history.Time).Seconds()
+			history.Result = "success"
+			if err != nil {
+				history.Result = "failure"
+				history.Error = err.Error()
+			}
+			RecordHistory(history)
+		}
+		if err != nil {
+			u.metrics.recordCheckError(p.Name)
+		}
+		if err != nil || deployed {
+			ev.Success = err == nil
+			if err != nil {
+				ev.Error = err.Error()
+			}
+			notify(ctx, config.Notify, ev, log)
+		}
+	}()
+
+	if p.Type == "image" && p.Image == "" {
+		log.Error("No image specified for project")
+		return fmt.Errorf("no image specified")
+	}
+	// The daemon doesn't wait for the lock: whoever holds it is already
+	// updating the project, and the next check will pick up anything left.
+	if !u.opts.DryRun && !u.opts.CheckOnly {
+		unlock, err := LockProject(ctx, p.Name, 0)
+		if errors.Is(err, ErrProjectLocked) && u.opts.LockWait > 0 {
+			log.Info("Another updatectl process is updating this project, waiting", "timeout", u.opts.LockWait)
+			unlock, err = LockProject(ctx, p.Name, u.opts.LockWait)
+		}
+		if errors.Is(err, ErrProjectLocked) && u.opts.LockWait == 0 {
+			log.Warn("Skipping, another updatectl process is updating this project")
+			return nil
+		}
+		if err != nil {
+			log.Error("Failed to lock project", "error", err)
+			return err
+		}
+		defer unlock()
+	}
+	// A missing (or empty) checkout is cloned from Repo unless --no-clone.
+	var clone bool
+	if p.Type != "image" {
+		switch empty, err := isEmptyDir(p.Path); {
+		case os.IsNotExist(err) || (err == nil && empty):
+			if p.Repo == "" || u.opts.NoClone {
+				log.Error("Path not found", "path", p.Path)
+				return fmt.Errorf("path not found: %s", p.Path)
+			}
+			clone = true
+		case !IsGitRepo(p.Path):
+			log.Error("Path is not a git repository", "path", p.Path)
+			return fmt.Errorf("%s exists but is not a git repository", p.Path)
+		}
+	}
+	if u.opts.DryRun {
+		if clone {
+			log.Info("Would clone repository", "dry_run", true, "repo", redactedURL(p.Repo), "path", p.Path, "branch", p.Branch)
+			return nil
+		}
+		return dryRunProject(ctx, p, log)
+	}
+	if u.opts.CheckOnly {
+		return checkProject(ctx, config, p, clone, log)
+	}
+
+	if sched := config.schedule(p); sched != nil && !u.opts.IgnoreSchedule && !sched.allows(time.Now()) {
+		return deferUpdate(ctx, config, p, clone, log)
+	}
+
+	if p.Type == "image" {
+		containerName := p.ContainerName
+		if containerName == "" {
+			containerName = p.Name
+		}
+
+		output, err := runCommand(ctx, Command{Name: "docker", Args: []string{"inspect", "-f", "{{.State.Running}}", containerName}})
+		containerRunning := err == nil && strings.TrimSpace(string(output)) == "true"
+
+		// Get current local image digest
+		currentDigest, err := getImageDigest(ctx, p.Image)
+		if err != nil || currentDigest == "" {
+			log.Info("Local image not found or no digest available")
+			currentDigest = ""
+		} else {
+			log.Debug("Current local digest", "digest", currentDigest)
+		}
+
+		// Get remote registry digest
+		remoteDigest, err := getRemoteImageDigest(ctx, p.Image)
+		if err != nil {
+			log.Warn("Could not check remote digest", "error", err)
+			// If we can't check remote, pull anyway to be safe
+			remoteDigest = ""
+		} else {
+			log.Debug("Remote registry digest", "digest", remoteDigest)
+		}
+
+		// Determine if image needs update
+		imageNeedsUpdate := false
+		if currentDigest == "" {
+			// No local image exists
+			imageNeedsUpdate = true
+		} else if remoteDigest == "" {
+			// Couldn't check remote, assume update needed
+			imageNeedsUpdate = true
+		} else {
+			// Extract just the sha256 hash from currentDigest if it's a full repo digest
+			// currentDigest might be like "ghcr.io/user/app@sha256:abc123"
+			// remoteDigest is like "sha256:abc123"
+			currentHash := currentDigest
+			if strings.Contains(currentDigest, "@") {
+				parts := strings.Split(currentDigest, "@")
+				if len(parts) == 2 {
+					currentHash = parts[1]
+				}
+			}
+
+			// Compare hashes
+			imageNeedsUpdate = currentHash != remoteDigest
+		}
+
+		if !imageNeedsUpdate && containerRunning && !u.opts.Force {
+			log.Info("Image already up to date and container running")
+			return nil
+		}
+
+		updating = true
+		history.From, history.To = currentDigest, remoteDigest
+		if imageNeedsUpdate {
+			if err := pullDockerImage(ctx, p.Image, log, cmdOut); err != nil {
+				log.Error("Failed to pull image", "image", p.Image, "error", err)
+				return fmt.Errorf("failed to pull image: %w", err)
+			}
+			log.Info("New image version detected", "image", p.Image)
+		} else if !containerRunning {
+			log.Info("Container not running, starting it", "container", containerName)
+		} else {
+			log.Info("Image already up to date, recreating the container anyway", "container", containerName)
+		}
+
+		if err := restartDockerContainer(ctx, p, log, cmdOut); err != nil {
+			log.Error("Failed to restart container", "error", err)
+			return fmt.Errorf("failed to restart container: %w", err)
+		}
+		log.Info("Container started successfully", "container", containerName)
+		if p.HealthCheck != nil {
+			err := runHealthCheck(ctx, *p.HealthCheck, log)
+			recordHealth(p.Name, err)
+			if err != nil {
+				log.Error("Health check failed", "error", err)
+				recordFailure(p.Name, fmt.Errorf("health check failed: %w", err))
+				return fmt.Errorf("health check failed: %w", err)
+			}
+		}
+		recordUpdate(p.Name)
+		deployed = true
+		ev.Commit = remoteDigest
+
+		return nil
+	}
+
+	// Whether anything changed is decided by comparing HEAD before and after,
+	// which holds for fast-forwards, merges and resets alike and doesn't
+	// depend on git's (possibly localized) output.
+	var before string
+	if !clone {
+		if before = gitHead(ctx, p.Path); before == "" {
+			log.Error("Could not read current commit", "path", p.Path)
+			return fmt.Errorf("could not read HEAD in %s", p.Path)
+		}
+	}
+
+	if !clone {
+		if err := gitCheckRemote(ctx, p); err != nil {
+			log.Error("Git remote not found", "error", err)
+			return err
+		}
+	}
+
+	stashed := false
+	if !clone {
+		dirty, err := gitDirtyFiles(ctx, p.Path)
+		if err != nil {
+			log.Error("Could not check for local changes", "error", err)
+			return fmt.Errorf("git status failed in %s: %w", p.Path, err)
+		}
+		if len(dirty) > 0 {
+			log := log.With("files", strings.Join(dirty, ","))
+			switch p.OnDirty {
+			case "stash":
+				if output, err := runGitCombined(ctx, "-C", p.Path, "stash", "push", "-m", "updatectl: local changes before update"); err != nil {
+					log.Error("Git stash failed", "error", err, "output", strings.TrimSpace(string(output)))
+					return fmt.Errorf("git stash failed: %w", err)
+				}
+				log.Warn("Stashed local changes before updating")
+				stashed = true
+				// Put the changes back if the update stops before the pop below.
+				defer func() {
+					if stashed {
+						gitPopStash(ctx, p, log)
+					}
+				}()
+			case "reset":
+				if output, err := runGitCombined(ctx, "-C", p.Path, "checkout", "--", "."); err != nil {
+					log.Error("Git checkout failed", "error", err, "output", strings.TrimSpace(string(output)))
+					return fmt.Errorf("discarding local changes failed: %w", err)
+				}
+				log.Warn("Discarded local changes before updating")
+			default:
+				log.Warn("Skipping, the checkout has local changes (set onDirty to stash or reset them)")
+				return nil
+			}
+		}
+	}
+
+	if !clone && (config.ShowChanges || u.opts.Confirm != nil) {
+		proceed, err := previewChanges(ctx, config, p, log, cmdOut)
+		if err != nil || !proceed {
+			return err
+		}
+	}
+
+	// ciPassed is the commit CI was found green for before updating.
+	var ciPassed string
+	if !clone && p.RequireCIStatus != nil {
+		commit, proceed, err := checkIncomingCI(ctx, config, p, log)
+		if err != nil || !proceed {
+			return err
+		}
+		ciPassed = commit
+	}
+
+	if !clone {
+		if err := syncSparseCheckout(ctx, p, log); err != nil {
+			return err
+		}
+	}
+
+	var gitOutput []byte
+	switch {
+	case clone:
+		log.Info("Cloning repository", "repo", redactedURL(p.Repo), "path", p.Path, "branch", p.Branch)
+		output, err := retryGit(ctx, config, p, log, func() ([]byte, error) {
+			return gitClone(ctx, p)
+		})
+		if err != nil {
+			log.Error("Git clone failed", "error", err, "output", strings.TrimSpace(string(output)))
+			return fmt.Errorf("git clone failed: %w", err)
+		}
+		gitOutput = output
+		if p.Ref != "" {
+			output, err := checkoutPinnedRef(ctx, config, p, log)
+			gitOutput = append(gitOutput, output...)
+			if err != nil {
+				// Remove the clone so the next check clones again instead
+				// of deploying the default branch.
+				if rerr := os.RemoveAll(p.Path); rerr != nil {
+					log.Error("Failed to remove clone", "error", rerr)
+				}
+				return fmt.Errorf("git checkout failed: %w", err)
+			}
+		}
+		if p.TracksTags() {
+			tag, output, err := checkoutNewTag(ctx, config, p, log)
+			gitOutput = append(gitOutput, output...)
+			if err != nil || tag == "" {
+				// Nothing deployable was cloned; remove it so the next check
+				// clones again instead of deploying the default branch.
+				if rerr := os.RemoveAll(p.Path); rerr != nil {
+					log.Error("Failed to remove clone", "error", rerr)
+				}
+				if err != nil {
+					return fmt.Errorf("git checkout failed: %w", err)
+				}
+				log.Info("No tags to deploy yet", "pattern", p.tagPattern())
+				return nil
+			}
+		}
+	case p.Ref != "":
+		log.Log(ctx, progressLevel(ctx), "Checking out pinned ref", "ref", p.Ref, "path", p.Path)
+		output, err := checkoutPinnedRef(ctx, config, p, log)
+		if err != nil {
+			return fmt.Errorf("git checkout failed: %w", err)
+		}
+		gitOutput = output
+	case p.TracksTags():
+		log.Log(ctx, progressLevel(ctx), "Checking for new tags", "pattern", p.tagPattern(), "path", p.Path)
+		tag, output, err := checkoutNewTag(ctx, config, p, log)
+		if err != nil {
+			return fmt.Errorf("git update failed: %w", err)
+		}
+		if tag == "" && !u.opts.Force {
+			log.Info("No new tags", "pattern", p.tagPattern(), "commit", before)
+			return nil
+		}
+		gitOutput = output
+	case p.Depth > 0:
+		log.Log(ctx, progressLevel(ctx), "Fetching latest changes", "path", p.Path, "depth", p.Depth)
+		output, err := retryGit(ctx, config, p, log, func() ([]byte, error) {
+			return gitShallowUpdate(ctx, p)
+		})
+		if err != nil {
+			log.Error("Git update failed", "depth", p.Depth, "error", err, "output", strings.TrimSpace(string(output)))
+			return fmt.Errorf("git update failed: %w", err)
+		}
+		gitOutput = output
+	case p.Branch != "":
+		log.Log(ctx, progressLevel(ctx), "Deploying branch", "branch", p.Branch, "path", p.Path)
+		output, err := retryGit(ctx, config, p, log, func() ([]byte, error) {
+			return gitResetToBranch(ctx, p)
+		})
+		if err != nil {
+			log.Error("Git update failed", "branch", p.Branch, "error", err, "output", strings.TrimSpace(string(output)))
+			return fmt.Errorf("git update failed: %w", err)
+		}
+		gitOutput = output
+	default:
+		log.Log(ctx, progressLevel(ctx), "Pulling latest changes", "path", p.Path, "strategy", p.pullStrategy())
+		output, err := retryGit(ctx, config, p, log, func() ([]byte, error) {
+			return gitPull(ctx, p)
+		})
+		if err != nil {
+			log.Error("Git pull failed", "error", err, "output", strings.TrimSpace(string(output)))
+			return fmt.Errorf("git pull failed: %w", err)
+		}
+		gitOutput = output
+	}
+	if p.VerifiesSignatures() {
+		if head := gitHead(ctx, p.Path); head != before {
+			if err := gitVerifyCommit(ctx, p, head); err != nil {
+				log.Error("Refusing to deploy commit without a valid signature", "commit", head, "error", err)
+				err = fmt.Errorf("signature verification failed for %s: %w", head, err)
+				recordFailure(p.Name, err)
+				undoGitUpdate(ctx, p, clone, before, log)
+				return err
+			}
+			log.Info("Verified commit signature", "commit", head)
+		}
+	}
+	if p.RequireCIStatus != nil {
+		// A fresh clone, or a commit pushed after CI was checked, is checked
+		// now and undone unless CI has passed for it.
+		if head := gitHead(ctx, p.Path); head != before && head != ciPassed {
+			if ok, err := ciAllows(ctx, p, head, log); err != nil || !ok {
+				undoGitUpdate(ctx, p, clone, before, log)
+				return err
+			}
+		}
+	}
+	if stashed {
+		// Reapply the local changes on top of the new commits before building.
+		stashed = false
+		gitPopStash(ctx, p, log)
+	}
+	if u.opts.Verbose {
+		cmdOut.Write(gitOutput)
+	} else {
+		log.Debug("Git output", "output", strings.TrimSpace(string(gitOutput)))
+	}
+	after := gitHead(ctx, p.Path)
+	if after == before && !u.opts.Force {
+		log.Info("No new commits", "commit", before)
+		return nil
+	}
+	if p.RollsBack() && before != "" && after == projectState(p.Name).RolledBackFrom {
+		log.Info("Skipping commit that was rolled back", "commit", after)
+		if output, err := gitResetHard(ctx, p.Path, before); err != nil {
+			log.Error("Git reset failed", "error", err, "output", strings.TrimSpace(string(output)))
+			return fmt.Errorf("git reset failed: %w", err)
+		}
+		return nil
+	}
+	if p.UpdatesSubmodules() {
+		log.Log(ctx, progressLevel(ctx), "Updating submodules")
+		output, err := retryGit(ctx, config, p, log, func() ([]byte, error) {
+			return runGitAuthCombined(ctx, p, "-C", p.Path, "submodule", "update", "--init", "--recursive")
+		})
+		if u.opts.Verbose {
+			cmdOut.Write(output)
+		}
+		if err != nil {
+			log.Error("Git submodule update failed", "error", err, "output", strings.TrimSpace(string(output)))
+			err = fmt.Errorf("git submodule update failed: %w", err)
+			recordFailure(p.Name, err)
+			// Go back to the previous commit so the next check tries again.
+			if before != "" {
+				if output, rerr := gitResetHard(ctx, p.Path, before); rerr != nil {
+					log.Error("Git reset failed", "error", rerr, "output", strings.TrimSpace(string(output)))
+				}
+			}
+			return err
+		}
+	}
+	if len(p.BuildPaths) > 0 && !clone && !u.opts.Force {
+		files, err := gitChangedFiles(ctx, p.Path, before, after)
+		if err != nil {
+			log.Warn("Could not list changed files, deploying anyway", "error", err)
+		} else if !p.matchesBuildPaths(files) {
+			log.Info("No relevant changes, skipping build", "from", before, "to", after, "files", len(files))
+			return nil
+		}
+	}
+	updating = true
+	history.From, history.To = before, after
+	ev.Commit = after
+	ev.Commits = gitCommitCount(ctx, p.Path, before, after)
+	switch {
+	case clone:
+		log.Info("Cloned", "commit", after)
+	case after == before:
+		log.Info("No new commits, deploying anyway", "commit", after)
+	default:
+		log.Info("Updated", "from", before, "to", after, "commits", ev.Commits)
+	}
+
+	env, err := projectEnv(p)
+	if err != nil {
+		log.Error("Failed to load environment", "error", err)
+		recordFailure(p.Name, err)
+		return err
+	}
+
+	if p.PreUpdate != "" {
+		log.Info("Running pre-update hook", "command", p.PreUpdate)
+		if err := runCommandAs(ctx, config.EffectiveShell(), p.PreUpdate, p.Path, p.RunAsUser, env, cmdOut); err != nil {
+			log.Error("Pre-update hook failed, aborting update", "error", err)
+			recordFailure(p.Name, fmt.Errorf("pre-update hook failed: %w", err))
+			return fmt.Errorf("pre-update hook failed: %w", err)
+		}
+	}
+
+	if len(p.BuildCommand) > 0 {
+		log.Info("Running build command", "command", p.BuildCommand)
+		start := time.Now()
+		err := u.RunBuild(ctx, config, p, cmdOut)
+		u.metrics.observeBuild(p.Name, time.Since(start))
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Warn("Build cancelled by shutdown", "error", err)
+				return ctx.Err()
+			}
+			log.Error("Build failed, skipping restart", "error", err)
+			BuildFailures.Add(1)
+			return handleDeployFailure(ctx, config, p, before, after, fmt.Errorf("build failed: %w", err), log, cmdOut)
+		}
+	}
+
+	if err := deployProject(ctx, p, log, cmdOut); err != nil {
+		log.Error("Restart failed", "error", err)
+		return handleDeployFailure(ctx, config, p, before, after, fmt.Errorf("restart failed: %w", err), log, cmdOut)
+	}
+
+	if p.HealthCheck != nil {
+		err := runHealthCheck(ctx, *p.HealthCheck, log)
+		recordHealth(p.Name, err)
+		if err != nil {
+			log.Error("Health check failed", "error", err)
+			return handleDeployFailure(ctx, config, p, before, after, fmt.Errorf("health check failed: %w", err), log, cmdOut)
+		}
+	}
+
+	if p.PostUpdate != "" {
+		log.Info("Running post-update hook", "command", p.PostUpdate)
+		if err := runCommandAs(ctx, config.EffectiveShell(), p.PostUpdate, p.Path, p.RunAsUser, env, cmdOut); err != nil {
+			log.Warn("Post-update hook failed", "error", err)
+		}
+	}
+
+	if p.PrunesImages() {
+		pruneDanglingImages(ctx, log)
+	}
+
+	recordUpdate(p.Name)
+	deployed = true
+	return nil
+}
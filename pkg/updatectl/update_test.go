@@ -0,0 +1,132 @@
+package updatectl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// useTempConfig points ConfigPath at a temporary directory, so the state,
+// history and locks an update writes stay out of the real ones.
+func useTempConfig(t *testing.T) {
+	t.Helper()
+	old := ConfigPath
+	ConfigPath = filepath.Join(t.TempDir(), "updatectl.yaml")
+	t.Cleanup(func() { ConfigPath = old })
+}
+
+func TestUpdateProjectImage(t *testing.T) {
+	useTempConfig(t)
+	p := Project{Name: "app", Type: "image", Image: "ghcr.io/acme/app:latest", Port: "8080:80"}
+	config := Config{Projects: []Project{p}}
+
+	tests := []struct {
+		name      string
+		responses []fakeResponse
+		wantErr   bool
+		wantCalls []string
+	}{
+		{
+			name: "up to date",
+			responses: []fakeResponse{
+				{command: "docker inspect -f", output: "true\n"},
+				{command: "docker inspect --format", output: "ghcr.io/acme/app@sha256:aaa\n"},
+				{command: "docker manifest inspect", output: "{\n  \"digest\": \"sha256:aaa\"\n}\n"},
+			},
+			wantCalls: []string{
+				"docker inspect -f {{.State.Running}} app",
+				"docker inspect --format={{index .RepoDigests 0}} ghcr.io/acme/app:latest",
+				"docker manifest inspect ghcr.io/acme/app:latest",
+			},
+		},
+		{
+			name: "new image",
+			responses: []fakeResponse{
+				{command: "docker inspect -f", output: "true\n"},
+				{command: "docker inspect --format", output: "ghcr.io/acme/app@sha256:aaa\n"},
+				{command: "docker manifest inspect", output: "{\n  \"digest\": \"sha256:bbb\"\n}\n"},
+			},
+			wantCalls: []string{
+				"docker inspect -f {{.State.Running}} app",
+				"docker inspect --format={{index .RepoDigests 0}} ghcr.io/acme/app:latest",
+				"docker manifest inspect ghcr.io/acme/app:latest",
+				"docker pull ghcr.io/acme/app:latest",
+				"docker stop app",
+				"docker rm app",
+				"docker run -d --name app -p 8080:80 --restart unless-stopped ghcr.io/acme/app:latest",
+			},
+		},
+		{
+			name: "pull fails",
+			responses: []fakeResponse{
+				{command: "docker inspect", err: errors.New("exit status 1")},
+				{command: "docker pull", output: "denied\n", err: errors.New("exit status 1")},
+			},
+			wantErr: true,
+			wantCalls: []string{
+				"docker inspect -f {{.State.Running}} app",
+				"docker inspect --format={{index .RepoDigests 0}} ghcr.io/acme/app:latest",
+				"docker manifest inspect ghcr.io/acme/app:latest",
+				"docker pull ghcr.io/acme/app:latest",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRunner{responses: tt.responses}
+			u := NewUpdater(Options{Runner: fake})
+			var out bytes.Buffer
+			err := u.UpdateProject(context.Background(), config, p, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateProject() error = %v, wantErr %v\n%s", err, tt.wantErr, out.String())
+			}
+			if got := fake.Calls(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Errorf("commands run:\n%q\nwant:\n%q", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestUpdateProjectGitBuildAndRestart(t *testing.T) {
+	useTempConfig(t)
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p := Project{Name: "app", Type: "pm2", Path: dir, BuildCommand: []string{"make"}}
+	config := Config{Shell: "sh", Projects: []Project{p}}
+
+	fake := &fakeRunner{responses: []fakeResponse{
+		{command: "git -C " + dir + " rev-parse HEAD", output: "aaa\n", once: true},
+		{command: "git -C " + dir + " rev-parse HEAD", output: "bbb\n"},
+		{command: "git -C " + dir + " rev-list --count", output: "1\n"},
+	}}
+	u := NewUpdater(Options{Runner: fake})
+	var out bytes.Buffer
+	if err := u.UpdateProject(context.Background(), config, p, &out); err != nil {
+		t.Fatalf("UpdateProject() error = %v\n%s", err, out.String())
+	}
+	// The build and restart must go through the Updater's Runner too.
+	calls := fake.Calls()
+	for _, want := range []string{"sh -c make", "pm2 restart app"} {
+		if !slices.Contains(calls, want) {
+			t.Errorf("%q not run; commands run:\n%q", want, calls)
+		}
+	}
+}
+
+func TestTransientGitErrorWithoutExitError(t *testing.T) {
+	// A CommandRunner other than ExecRunner needn't return *exec.ExitError.
+	err := errors.New("exit status 128")
+	if !isTransientGitError([]byte("fatal: unable to access 'https://example.com/': Could not resolve host: example.com\n"), err) {
+		t.Error("network failure not seen as transient")
+	}
+	if isTransientGitError([]byte("fatal: unable to access: Could not resolve host\n"), context.Canceled) {
+		t.Error("cancelled command seen as transient")
+	}
+}
@@ -0,0 +1,128 @@
+package updatectl
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Options control how an Updater runs updates. The updatectl command sets
+// them from its flags.
+type Options struct {
+	// DryRun makes updates only report what they would do: nothing is
+	// pulled, built or restarted. Set from --dry-run.
+	DryRun bool
+	// CheckOnly makes updates only report which projects are behind their
+	// remote: nothing is pulled, built or restarted. Set from watch
+	// --check-only.
+	CheckOnly bool
+	// NoClone makes a missing project path an error instead of being cloned
+	// from the project's repo. Set from --no-clone.
+	NoClone bool
+	// IgnoreSchedule deploys outside the maintenance window. Set from once
+	// --ignore-schedule.
+	IgnoreSchedule bool
+	// Force builds and restarts a project even when there is nothing new to
+	// deploy, or its new commits touch none of its buildPaths. Set from
+	// build --force.
+	Force bool
+	// LockWait is how long an update waits for another updatectl process
+	// that is updating the same project. Zero, as in the daemon, skips the
+	// project right away; otherwise a project still locked after LockWait
+	// fails with ErrProjectLocked. Set from build --pull's --lock-timeout.
+	LockWait time.Duration
+	// Verbose logs progress messages and shows git output. Set from
+	// --verbose.
+	Verbose bool
+	// Confirm, when set, is called with a question before each deploy,
+	// after the incoming commits have been printed; the update only goes
+	// ahead if it returns true. Set for once --interactive.
+	Confirm func(question string) bool
+	// Runner runs every command an update uses. Nil means ExecRunner; tests
+	// use a fake to script what git, docker and builds print.
+	Runner CommandRunner
+}
+
+// Updater runs updates with a fixed set of Options and keeps the metrics of
+// the updates it runs. Updaters with different options may be used from the
+// same process at once.
+type Updater struct {
+	opts    Options
+	metrics *daemonMetrics
+	cycles  *cycleTracker
+}
+
+// NewUpdater returns an Updater that runs updates with opts.
+func NewUpdater(opts Options) *Updater {
+	if opts.Runner == nil {
+		opts.Runner = ExecRunner{}
+	}
+	return &Updater{
+		opts:    opts,
+		metrics: newDaemonMetrics(),
+		cycles:  &cycleTracker{started: time.Now()},
+	}
+}
+
+// Options returns the options u was created with.
+func (u *Updater) Options() Options {
+	return u.opts
+}
+
+// defaultUpdater runs the package-level functions such as RunCycle, and any
+// git or docker command run outside an Updater. Those functions are for
+// callers outside the package: code in it calls the methods of the Updater
+// in ctx instead, so as not to drop the caller's options.
+var defaultUpdater = NewUpdater(Options{})
+
+type updaterKey struct{}
+
+// withUpdater returns ctx carrying u, so the helpers an update calls run
+// their commands through u's Runner and see its options.
+func (u *Updater) withUpdater(ctx context.Context) context.Context {
+	if updaterFrom(ctx) == u {
+		return ctx
+	}
+	return context.WithValue(ctx, updaterKey{}, u)
+}
+
+// updaterFrom returns the Updater running the update ctx belongs to, or the
+// default one.
+func updaterFrom(ctx context.Context) *Updater {
+	if u, ok := ctx.Value(updaterKey{}).(*Updater); ok {
+		return u
+	}
+	return defaultUpdater
+}
+
+// RunCycle is Updater.RunCycle with the default Options.
+func RunCycle(ctx context.Context, config Config) error {
+	return defaultUpdater.RunCycle(ctx, config)
+}
+
+// RunCycleResults is Updater.RunCycleResults with the default Options.
+func RunCycleResults(ctx context.Context, config Config) ([]ProjectResult, error) {
+	return defaultUpdater.RunCycleResults(ctx, config)
+}
+
+// UpdateProject is Updater.UpdateProject with the default Options.
+func UpdateProject(ctx context.Context, config Config, p Project, out io.Writer) error {
+	return defaultUpdater.UpdateProject(ctx, config, p, out)
+}
+
+// UpdateProjectResult is Updater.UpdateProjectResult with the default
+// Options.
+func UpdateProjectResult(ctx context.Context, config Config, p Project, out io.Writer) (ProjectResult, error) {
+	return defaultUpdater.UpdateProjectResult(ctx, config, p, out)
+}
+
+// RunBuild is Updater.RunBuild with the default Options.
+func RunBuild(ctx context.Context, config Config, p Project, out io.Writer) error {
+	return defaultUpdater.RunBuild(ctx, config, p, out)
+}
+
+// RestartProject is Updater.RestartProject with the default Options.
+func RestartProject(ctx context.Context, p Project, log *slog.Logger, out io.Writer) error {
+	return defaultUpdater.RestartProject(ctx, p, log, out)
+}
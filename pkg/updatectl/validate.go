@@ -0,0 +1,301 @@
+package updatectl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ProjectTypes lists every supported Project.Type.
+var ProjectTypes = []string{"docker", "pm2", "systemd", "static", "image", "kubernetes", "docker-compose"}
+
+// scpLikeRepo matches scp-style git remotes such as git@github.com:user/repo.git.
+var scpLikeRepo = regexp.MustCompile(`^[\w.-]+@[\w.-]+:.+`)
+
+// Validate checks the config for mistakes that would otherwise only show up
+// at runtime. Every problem found is reported, not just the first.
+func (c Config) Validate() error {
+	var problems []error
+	if c.Defaults != nil {
+		problems = append(problems, validateDefaults(*c.Defaults)...)
+	}
+	// Check the config as it is used: with defaults applied and secret
+	// references resolved.
+	c, secretProblems := c.withSecrets()
+	problems = append(problems, secretProblems...)
+
+	if c.Cron != "" {
+		if _, err := parseCron(c.Cron); err != nil {
+			problems = append(problems, err)
+		}
+	} else if c.IntervalSeconds() <= 0 {
+		problems = append(problems, errors.New("interval must be greater than 0 (or set cron)"))
+	}
+
+	if c.ProjectsDir != "" {
+		if !filepath.IsAbs(c.ProjectsDir) {
+			problems = append(problems, errors.New("projectsDir must be an absolute path"))
+		} else if info, err := os.Stat(c.ProjectsDir); err != nil {
+			problems = append(problems, fmt.Errorf("projectsDir: %w", err))
+		} else if !info.IsDir() {
+			problems = append(problems, fmt.Errorf("projectsDir %s is not a directory", c.ProjectsDir))
+		}
+	}
+
+	if c.IntervalJitterSeconds < 0 {
+		problems = append(problems, errors.New("intervalJitterSeconds must not be negative"))
+	}
+
+	if c.GitConcurrency < 0 {
+		problems = append(problems, errors.New("gitConcurrency must not be negative"))
+	}
+
+	if c.BuildLogs < 0 {
+		problems = append(problems, errors.New("buildLogs must not be negative"))
+	}
+
+	if c.Retries < 0 || c.RetryBackoffSeconds < 0 {
+		problems = append(problems, errors.New("retries and retryBackoffSeconds must not be negative"))
+	}
+
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			problems = append(problems, fmt.Errorf("metricsAddr %q must be host:port or :port", c.MetricsAddr))
+		}
+	}
+
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err != nil || u.Host == "" || !slices.Contains([]string{"http", "https", "socks5"}, u.Scheme) {
+			problems = append(problems, fmt.Errorf("proxy %q must be an http://, https:// or socks5:// URL", c.Proxy))
+		}
+	}
+
+	if c.Webhook != nil {
+		if _, _, err := net.SplitHostPort(c.Webhook.Addr); err != nil {
+			problems = append(problems, fmt.Errorf("webhook addr %q must be host:port or :port", c.Webhook.Addr))
+		} else if c.Webhook.Addr == c.MetricsAddr {
+			problems = append(problems, errors.New("webhook addr and metricsAddr must differ"))
+		}
+	}
+
+	if c.API != nil {
+		if _, _, err := net.SplitHostPort(c.API.Addr); err != nil {
+			problems = append(problems, fmt.Errorf("api addr %q must be host:port or :port", c.API.Addr))
+		} else if c.API.Addr == c.MetricsAddr || (c.Webhook != nil && c.API.Addr == c.Webhook.Addr) {
+			problems = append(problems, errors.New("api addr must differ from metricsAddr and the webhook addr"))
+		}
+		if c.API.Token == "" {
+			problems = append(problems, errors.New("api token is required"))
+		}
+	}
+
+	if c.Schedule != nil {
+		if err := c.Schedule.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("schedule: %w", err))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, p := range c.Projects {
+		label := fmt.Sprintf("project %d", i+1)
+		if p.Name == "" {
+			problems = append(problems, fmt.Errorf("%s: name is required", label))
+		} else {
+			label = fmt.Sprintf("project %q", p.Name)
+			if seen[p.Name] {
+				problems = append(problems, fmt.Errorf("%s: duplicate name", label))
+			}
+			seen[p.Name] = true
+		}
+
+		if !slices.Contains(ProjectTypes, p.Type) {
+			problems = append(problems, fmt.Errorf("%s: unknown type %q (expected one of %s)", label, p.Type, strings.Join(ProjectTypes, ", ")))
+		}
+		if p.Type == "image" {
+			if p.Image == "" {
+				problems = append(problems, fmt.Errorf("%s: image is required for image type", label))
+			}
+		} else if p.Path == "" {
+			problems = append(problems, fmt.Errorf("%s: path is required", label))
+		}
+		if p.Repo != "" && !isPlausibleRepoURL(p.Repo) {
+			problems = append(problems, fmt.Errorf("%s: repo %q doesn't look like a git URL", label, p.Repo))
+		}
+		if p.Interval < 0 {
+			problems = append(problems, fmt.Errorf("%s: interval must not be negative", label))
+		}
+		for from, to := range p.URLRewrites {
+			if from == "" || to == "" {
+				problems = append(problems, fmt.Errorf("%s: urlRewrites entries need both a prefix and a replacement", label))
+				break
+			}
+		}
+		if p.Schedule != nil {
+			if err := p.Schedule.Validate(); err != nil {
+				problems = append(problems, fmt.Errorf("%s: schedule: %w", label, err))
+			}
+		}
+		if p.Cron != "" {
+			if _, err := parseCron(p.Cron); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", label, err))
+			}
+		}
+		if p.OnDirty != "" && !slices.Contains(onDirtyPolicies, p.OnDirty) {
+			problems = append(problems, fmt.Errorf("%s: unknown onDirty %q (expected one of %s)", label, p.OnDirty, strings.Join(onDirtyPolicies, ", ")))
+		}
+		if p.PullStrategy != "" && !slices.Contains(pullStrategies, p.PullStrategy) {
+			problems = append(problems, fmt.Errorf("%s: unknown pullStrategy %q (expected one of %s)", label, p.PullStrategy, strings.Join(pullStrategies, ", ")))
+		} else if p.PullStrategy != "" && p.PullStrategy != "reset" && (p.Branch != "" || p.Ref != "" || p.TracksTags() || p.Depth > 0) {
+			problems = append(problems, fmt.Errorf("%s: pullStrategy %s can't be combined with branch, ref, trackTags or depth, which always reset the checkout", label, p.PullStrategy))
+		}
+		if p.PrunesImages() && p.Type != "docker" && p.Type != "docker-compose" {
+			problems = append(problems, fmt.Errorf("%s: pruneImages is only supported for docker and docker-compose types", label))
+		}
+		if p.AllowedSigners != "" {
+			if !p.VerifiesSignatures() {
+				problems = append(problems, fmt.Errorf("%s: allowedSigners requires verifySignature", label))
+			} else if _, err := os.Stat(p.AllowedSigners); err != nil {
+				problems = append(problems, fmt.Errorf("%s: allowedSigners: %w", label, err))
+			}
+		}
+		if p.TracksTags() {
+			if p.Branch != "" {
+				problems = append(problems, fmt.Errorf("%s: branch and trackTags can't both be set", label))
+			}
+			if p.Type == "image" {
+				problems = append(problems, fmt.Errorf("%s: trackTags is not supported for image type", label))
+			}
+		}
+		if p.Ref != "" {
+			if p.Branch != "" || p.TracksTags() {
+				problems = append(problems, fmt.Errorf("%s: ref can't be combined with branch or trackTags", label))
+			}
+			if p.Type == "image" {
+				problems = append(problems, fmt.Errorf("%s: ref is not supported for image type", label))
+			}
+			if strings.HasPrefix(p.Ref, "-") || strings.ContainsAny(p.Ref, " \t:") {
+				problems = append(problems, fmt.Errorf("%s: invalid ref %q", label, p.Ref))
+			}
+		}
+		if p.TagPattern != "" {
+			if !p.TracksTags() {
+				problems = append(problems, fmt.Errorf("%s: tagPattern requires trackTags", label))
+			} else if _, err := path.Match(p.TagPattern, ""); err != nil {
+				problems = append(problems, fmt.Errorf("%s: invalid tagPattern %q", label, p.TagPattern))
+			}
+		}
+		if p.VerifiesSignatures() && p.Type == "image" {
+			problems = append(problems, fmt.Errorf("%s: verifySignature is not supported for image type", label))
+		}
+		if p.RunAsUser != "" {
+			if err := checkRunAsUser(p.RunAsUser); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", label, err))
+			}
+		}
+		for _, pattern := range p.BuildPaths {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				problems = append(problems, fmt.Errorf("%s: invalid buildPaths pattern %q", label, pattern))
+			}
+		}
+		if len(p.BuildPaths) > 0 && p.Type == "image" {
+			problems = append(problems, fmt.Errorf("%s: buildPaths is not supported for image type", label))
+		}
+		if p.BuildDir != "" && len(p.BuildCommand) == 0 {
+			problems = append(problems, fmt.Errorf("%s: buildDir requires buildCommand", label))
+		}
+		if p.DeployPath != "" {
+			switch {
+			case p.Type != "static":
+				problems = append(problems, fmt.Errorf("%s: deployPath is only supported for the static type", label))
+			case !filepath.IsAbs(p.DeployPath):
+				problems = append(problems, fmt.Errorf("%s: deployPath must be an absolute path", label))
+			case p.Path != "" && (insideDir(p.DeployPath, p.Path) || insideDir(p.Path, p.DeployPath)):
+				problems = append(problems, fmt.Errorf("%s: deployPath must be outside path", label))
+			}
+		}
+		if p.OutputDir != "" && p.DeployPath == "" {
+			problems = append(problems, fmt.Errorf("%s: outputDir requires deployPath", label))
+		}
+		if p.UsesRsync() && p.DeployPath == "" {
+			problems = append(problems, fmt.Errorf("%s: rsync requires deployPath", label))
+		}
+		if slices.Contains(p.BuildCommand, "") {
+			problems = append(problems, fmt.Errorf("%s: buildCommand has an empty step", label))
+		}
+		if p.Depth < 0 {
+			problems = append(problems, fmt.Errorf("%s: depth must not be negative", label))
+		}
+		problems = append(problems, validateSparsePaths(label, p)...)
+		if p.Retries < 0 || p.RetryBackoffSeconds < 0 {
+			problems = append(problems, fmt.Errorf("%s: retries and retryBackoffSeconds must not be negative", label))
+		}
+		if ci := p.RequireCIStatus; ci != nil {
+			if !slices.Contains(ciProviders, ci.Provider) {
+				problems = append(problems, fmt.Errorf("%s: unknown requireCIStatus.provider %q (expected one of %s)", label, ci.Provider, strings.Join(ciProviders, ", ")))
+			}
+			if p.Type == "image" {
+				problems = append(problems, fmt.Errorf("%s: requireCIStatus is not supported for image type", label))
+			}
+			if ci.Repository != "" {
+				if owner, name, ok := strings.Cut(ci.Repository, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+					problems = append(problems, fmt.Errorf("%s: requireCIStatus.repository %q must be owner/name", label, ci.Repository))
+				}
+			} else if _, ok := githubRepository(p.Repo); p.Repo != "" && !ok {
+				problems = append(problems, fmt.Errorf("%s: can't tell the GitHub repository from repo; set requireCIStatus.repository", label))
+			}
+			if ci.APIURL != "" {
+				if u, err := url.Parse(ci.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					problems = append(problems, fmt.Errorf("%s: requireCIStatus.apiURL %q must be an http(s) URL", label, ci.APIURL))
+				}
+			}
+		}
+		if hc := p.HealthCheck; hc != nil {
+			if u, err := url.Parse(hc.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				problems = append(problems, fmt.Errorf("%s: healthCheck.url %q must be an http(s) URL", label, hc.URL))
+			}
+			if hc.ExpectedStatus != 0 && (hc.ExpectedStatus < 100 || hc.ExpectedStatus > 599) {
+				problems = append(problems, fmt.Errorf("%s: healthCheck.expectedStatus %d is not an HTTP status", label, hc.ExpectedStatus))
+			}
+			if hc.TimeoutSeconds < 0 || hc.Retries < 0 {
+				problems = append(problems, fmt.Errorf("%s: healthCheck timeoutSeconds and retries must not be negative", label))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return validationErrors(problems)
+}
+
+// validationErrors reports every problem found by Validate, one per line.
+type validationErrors []error
+
+func (e validationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "  - " + err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e validationErrors) Unwrap() []error {
+	return e
+}
+
+// isPlausibleRepoURL reports whether repo looks like something git can clone.
+func isPlausibleRepoURL(repo string) bool {
+	for _, scheme := range []string{"https://", "http://", "ssh://", "git://", "file://"} {
+		if strings.HasPrefix(repo, scheme) && len(repo) > len(scheme) {
+			return true
+		}
+	}
+	return scpLikeRepo.MatchString(repo)
+}
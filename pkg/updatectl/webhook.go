@@ -0,0 +1,335 @@
+package updatectl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookConfig enables push-triggered updates in the watch daemon.
+type WebhookConfig struct {
+	Addr string `yaml:"addr" json:"addr" toml:"addr"` // Address to listen on, e.g. ":9000"
+}
+
+// maxWebhookBody bounds the size of a webhook payload; GitHub sends at most 25MB.
+const maxWebhookBody = 25 << 20
+
+// WebhookServer receives push webhooks at /hooks/<project>, or at /hooks for
+// projects matched by repository, and queues the project for an immediate
+// check by the watch loop. Running the update in the loop rather than the
+// handler means a push that arrives mid-update is picked up by a second
+// check instead of being lost.
+type WebhookServer struct {
+	mu       sync.Mutex
+	config   Config
+	triggers chan string
+}
+
+// NewWebhookServer returns a server for the projects in config.
+func NewWebhookServer(config Config) *WebhookServer {
+	return &WebhookServer{config: config, triggers: make(chan string, 64)}
+}
+
+// SetConfig replaces the projects and secrets used to validate requests
+// after the config is reloaded.
+func (s *WebhookServer) SetConfig(config Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// Triggers returns the names of projects that a webhook has queued for an
+// immediate check.
+func (s *WebhookServer) Triggers() <-chan string {
+	return s.triggers
+}
+
+func (s *WebhookServer) project(name string) (Project, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.config.Projects {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Project{}, false
+}
+
+// Serve listens on addr until ctx is done, then shuts the server down.
+func (s *WebhookServer) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/{project}", s.handle)
+	mux.HandleFunc("POST /hooks", s.handleAny)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *WebhookServer) handle(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("project")
+	log := Logger.With("project", name, "remote", r.RemoteAddr)
+
+	// Unknown projects and projects without a secret get the same answer,
+	// so the endpoint doesn't reveal which projects exist.
+	p, ok := s.project(name)
+	if !ok || p.WebhookSecret == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if err := verifyWebhook(r.Header, body, p.WebhookSecret); err != nil {
+		log.Warn("Rejected webhook", "error", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	switch event := webhookEvent(r.Header); event {
+	case "ping":
+		fmt.Fprintln(w, "pong")
+		return
+	case "push", "Push Hook":
+	default:
+		fmt.Fprintf(w, "ignored %s event\n", event)
+		return
+	}
+	if ref := pushRef(body); ref != "" && !deploysRef(p, deployedBranch(r.Context(), p), ref) {
+		fmt.Fprintf(w, "ignored push to %s\n", ref)
+		return
+	}
+
+	s.trigger(p.Name)
+	log.Info("Webhook received, update queued")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "update queued")
+}
+
+// handleAny serves the generic /hooks endpoint: the projects to update are
+// the ones whose repo matches the payload's repository and whose deployed
+// branch matches the pushed ref. Each candidate's secret is tried, so
+// projects sharing a repo may use different secrets.
+func (s *WebhookServer) handleAny(w http.ResponseWriter, r *http.Request) {
+	log := Logger.With("remote", r.RemoteAddr)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	payload := parsePushPayload(body)
+
+	s.mu.Lock()
+	var candidates []Project
+	for _, p := range s.config.Projects {
+		if p.WebhookSecret != "" && p.Repo != "" && slices.ContainsFunc(payload.urls(), func(u string) bool { return SameRepo(u, p.Repo) }) {
+			candidates = append(candidates, p)
+		}
+	}
+	s.mu.Unlock()
+	if len(candidates) == 0 {
+		http.Error(w, "no matching project", http.StatusNotFound)
+		return
+	}
+
+	var verified []Project
+	for _, p := range candidates {
+		if verifyWebhook(r.Header, body, p.WebhookSecret) == nil {
+			verified = append(verified, p)
+		}
+	}
+	if len(verified) == 0 {
+		log.Warn("Rejected webhook", "repo", redactedURL(candidates[0].Repo), "error", "signature matches no project")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	switch event := webhookEvent(r.Header); event {
+	case "ping":
+		fmt.Fprintln(w, "pong")
+		return
+	case "push", "Push Hook":
+	default:
+		fmt.Fprintf(w, "ignored %s event\n", event)
+		return
+	}
+
+	var queued []string
+	for _, p := range verified {
+		if !deploysRef(p, deployedBranch(r.Context(), p), payload.Ref) {
+			continue
+		}
+		s.trigger(p.Name)
+		queued = append(queued, p.Name)
+	}
+	if len(queued) == 0 {
+		fmt.Fprintf(w, "ignored push to %s\n", payload.Ref)
+		return
+	}
+	log.Info("Webhook received, update queued", "projects", strings.Join(queued, ","), "ref", payload.Ref)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "update queued for %s\n", strings.Join(queued, ", "))
+}
+
+// trigger queues the named project for an immediate check.
+func (s *WebhookServer) trigger(name string) {
+	select {
+	case s.triggers <- name:
+	default:
+		// The queue is full, so checks are already pending.
+	}
+}
+
+// verifyWebhook checks a GitHub-style X-Hub-Signature-256 HMAC of body or a
+// GitLab-style X-Gitlab-Token against secret.
+func verifyWebhook(h http.Header, body []byte, secret string) error {
+	if sig := h.Get("X-Hub-Signature-256"); sig != "" {
+		got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+		if err != nil {
+			return errors.New("malformed X-Hub-Signature-256")
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if !hmac.Equal(got, mac.Sum(nil)) {
+			return errors.New("X-Hub-Signature-256 mismatch")
+		}
+		return nil
+	}
+	if token := h.Get("X-Gitlab-Token"); token != "" {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return errors.New("X-Gitlab-Token mismatch")
+		}
+		return nil
+	}
+	return errors.New("no X-Hub-Signature-256 or X-Gitlab-Token header")
+}
+
+// webhookEvent returns the event type from GitHub or GitLab headers.
+func webhookEvent(h http.Header) string {
+	if event := h.Get("X-GitHub-Event"); event != "" {
+		return event
+	}
+	return h.Get("X-Gitlab-Event")
+}
+
+// pushRef returns the ref a push event payload refers to, or "" if the body
+// doesn't say.
+func pushRef(body []byte) string {
+	return parsePushPayload(body).Ref
+}
+
+// pushPayload holds the parts of GitHub and GitLab push payloads used to
+// find the projects a push is for.
+type pushPayload struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`    // GitHub
+		SSHURL   string `json:"ssh_url"`      // GitHub
+		GitURL   string `json:"git_url"`      // GitHub
+		HTMLURL  string `json:"html_url"`     // GitHub
+		HTTPURL  string `json:"git_http_url"` // GitLab
+		GitSSH   string `json:"git_ssh_url"`  // GitLab
+	} `json:"repository"`
+	Project struct {
+		HTTPURL string `json:"git_http_url"` // GitLab
+		SSHURL  string `json:"git_ssh_url"`  // GitLab
+		WebURL  string `json:"web_url"`      // GitLab
+	} `json:"project"`
+}
+
+func parsePushPayload(body []byte) pushPayload {
+	var payload pushPayload
+	json.Unmarshal(body, &payload)
+	return payload
+}
+
+// urls returns every repository URL the payload mentions.
+func (p pushPayload) urls() []string {
+	var urls []string
+	for _, u := range []string{
+		p.Repository.CloneURL, p.Repository.SSHURL, p.Repository.GitURL, p.Repository.HTMLURL,
+		p.Repository.HTTPURL, p.Repository.GitSSH,
+		p.Project.HTTPURL, p.Project.SSHURL, p.Project.WebURL,
+	} {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// SameRepo reports whether two git URLs name the same repository, ignoring
+// the scheme, credentials, a trailing .git and case, so that
+// https://github.com/org/app.git, git@github.com:org/app and
+// ssh://git@github.com/org/app.git all match.
+func SameRepo(a, b string) bool {
+	return normalizeRepoURL(a) == normalizeRepoURL(b)
+}
+
+func normalizeRepoURL(raw string) string {
+	s := strings.ToLower(strings.TrimSpace(raw))
+	if _, rest, ok := strings.Cut(s, "://"); ok {
+		s = rest
+	} else if host, path, ok := strings.Cut(s, ":"); ok && scpLikeRepo.MatchString(raw) {
+		// scp-style git@host:path
+		s = host + "/" + path
+	}
+	host, path, _ := strings.Cut(s, "/")
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	// Drop a port, such as ssh://git@host:22/org/app.
+	host, _, _ = strings.Cut(host, ":")
+	return host + "/" + strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+}
+
+// deploysRef reports whether a push to ref can change what p deploys: never
+// if p is pinned to a ref, a tag matching its pattern if p tracks tags,
+// otherwise a push to branch, or any push if branch is "".
+func deploysRef(p Project, branch, ref string) bool {
+	if p.Ref != "" {
+		// A pinned project only moves when its config changes.
+		return false
+	}
+	if p.TracksTags() {
+		tag, ok := strings.CutPrefix(ref, "refs/tags/")
+		matched, _ := path.Match(p.tagPattern(), tag)
+		return ok && matched
+	}
+	return branch == "" || ref == "refs/heads/"+branch
+}
+
+// deployedBranch returns the branch p deploys: its pinned branch, or the
+// checkout's upstream branch. It returns "" if that can't be determined, for
+// example before the first clone.
+func deployedBranch(ctx context.Context, p Project) string {
+	if p.Branch != "" {
+		return p.Branch
+	}
+	if _, branch, err := gitUpstream(ctx, p); err == nil {
+		return branch
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a project to the config",
+	Long: `Add a project to the config. Values not given as flags are prompted for.
+
+Comments and the order of keys in the config file are kept, except in a TOML
+config, which is rewritten from its parsed contents.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := updatectl.ResolveConfigPath()
+		config, err := updatectl.ReadConfigFile(path)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		scanner := bufio.NewScanner(os.Stdin)
+		flagOrPrompt := func(flag, label string) string {
+			if cmd.Flags().Changed(flag) {
+				value, _ := cmd.Flags().GetString(flag)
+				return value
+			}
+			fmt.Printf("%s: ", label)
+			scanner.Scan()
+			return strings.TrimSpace(scanner.Text())
+		}
+
+		p := updatectl.Project{Name: flagOrPrompt("name", "Name")}
+		for _, existing := range config.Projects {
+			if existing.Name == p.Name {
+				fmt.Printf("Error: project %s already exists\n", p.Name)
+				os.Exit(1)
+			}
+		}
+		p.Type = flagOrPrompt("type", fmt.Sprintf("Type (%s)", strings.Join(updatectl.ProjectTypes, ", ")))
+		p.Path = flagOrPrompt("path", "Path")
+		p.Repo = flagOrPrompt("repo", "Repo URL (optional)")
+		if build := flagOrPrompt("build", "Build command (optional)"); build != "" {
+			p.BuildCommand = updatectl.Commands{build}
+		}
+
+		config.Projects = append(config.Projects, p)
+		if err := config.Validate(); err != nil {
+			fmt.Printf("Error: project is invalid:\n%v\n", err)
+			os.Exit(1)
+		}
+		if err := editConfigFile(path, func(root *yaml.Node) error { return appendProject(root, p) }); err != nil {
+			fmt.Println("Failed to write config:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added project %s to %s\n", p.Name, path)
+	},
+}
+
+func init() {
+	addCmd.Flags().String("name", "", "Project name")
+	addCmd.Flags().String("path", "", "Local path to the project")
+	addCmd.Flags().String("repo", "", "Git repository URL")
+	addCmd.Flags().String("type", "", "Project type")
+	addCmd.Flags().String("build", "", "Build command")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+// buildLogResult returns the outcome line written by finishBuildLog, or
+// "running" if the build hasn't finished (or updatectl was killed during it).
+func buildLogResult(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "unreadable"
+	}
+	last := strings.TrimSpace(string(lastLines(data, 1)))
+	if rest, ok := strings.CutPrefix(last, "# "); ok && (strings.HasPrefix(rest, "succeeded") || strings.HasPrefix(rest, "failed")) {
+		return rest
+	}
+	return "running"
+}
+
+// printBuildLogs lists the named project's build logs, newest first and
+// numbered for logs --build.
+func printBuildLogs(name string, w io.Writer) error {
+	paths, err := updatectl.ListBuildLogs(name)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(w, "No build logs for %s\n", name)
+		return nil
+	}
+	for i, path := range paths {
+		stamp := strings.TrimSuffix(filepath.Base(path), ".log")
+		when := stamp
+		if t, err := time.ParseInLocation(updatectl.BuildLogTimeFormat, stamp, time.Local); err == nil {
+			when = t.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%3d  %s  %s\n", i+1, when, buildLogResult(path))
+	}
+	return nil
+}
+
+// showBuildLog implements logs <project>: it lists the project's build logs
+// or prints one of them. The whole log is printed unless --lines or --tail
+// was given.
+func showBuildLog(cmd *cobra.Command, name string, lines int, follow bool) {
+	if list, _ := cmd.Flags().GetBool("list"); list {
+		if err := printBuildLogs(name, os.Stdout); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	paths, err := updatectl.ListBuildLogs(name)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	n, _ := cmd.Flags().GetInt("build")
+	if n < 1 || n > len(paths) {
+		if len(paths) == 0 {
+			fmt.Printf("No build logs for %s\n", name)
+		} else {
+			fmt.Printf("No build log %d for %s, there are %d\n", n, name, len(paths))
+		}
+		os.Exit(1)
+	}
+	if !cmd.Flags().Changed("lines") && !cmd.Flags().Changed("tail") {
+		lines = math.MaxInt
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := tailFile(ctx, paths[n-1], lines, follow, os.Stdout); err != nil {
+		fmt.Printf("Failed to read %s: %v\n", paths[n-1], err)
+		os.Exit(1)
+	}
+}
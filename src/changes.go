@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+)
+
+// printPendingChanges tells the user running build, on w, which commits on p's
+// remote are not part of the checkout about to be built, since build never
+// pulls.
+func printPendingChanges(ctx context.Context, w io.Writer, p updatectl.Project) {
+	commits, err := updatectl.PendingCommits(ctx, p)
+	if err != nil {
+		fmt.Fprintf(w, "Could not check %s for new commits: %v\n", p.Name, err)
+		return
+	}
+	if len(commits) == 0 {
+		fmt.Fprintf(w, "%s is up to date with its remote\n", p.Name)
+		return
+	}
+	fmt.Fprintf(w, "%d commit(s) on the remote are not in this build of %s (run 'updatectl once %s' to deploy them):\n", len(commits), p.Name, p.Name)
+	for _, c := range commits {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
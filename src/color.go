@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"runtime"
+)
+
+// noColor is set by --no-color.
+var noColor bool
+
+// colorOutput reports whether the interactive commands color their output:
+// only when stdout is a terminal and neither --no-color nor the NO_COLOR
+// convention (https://no-color.org) turns it off. Logs are never colored.
+var colorOutput bool
+
+// setupColor decides colorOutput once the flags are parsed.
+func setupColor() {
+	colorOutput = !noColor && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(os.Stdout)
+	if runtime.GOOS == "windows" && os.Getenv("WT_SESSION") == "" {
+		// The classic console shows escape codes literally; Windows
+		// Terminal understands them.
+		colorOutput = false
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func colorize(code, s string) string {
+	if !colorOutput {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func green(s string) string  { return colorize("32", s) }
+func yellow(s string) string { return colorize("33", s) }
+func red(s string) string    { return colorize("31", s) }
+
+// Marks put in front of results so they can be told apart at a glance: done,
+// nothing to do, and failed.
+func markOK() string      { return green("✓") }
+func markNeutral() string { return yellow("-") }
+func markFailed() string  { return red("✗") }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"gopkg.in/yaml.v3"
+)
+
+// editConfigFile applies edit to the YAML node tree of the config at path and
+// writes it back atomically. Working on the node tree rather than a Config
+// keeps the user's comments and key order; only the nodes edit touches change.
+// JSON configs are parsed the same way, JSON being valid YAML, and written
+// back as JSON. TOML configs are rewritten from their parsed contents, so
+// their comments are lost.
+func editConfigFile(path string, edit func(root *yaml.Node) error) error {
+	if updatectl.ConfigFormat(path) == "toml" {
+		return editTOMLConfigFile(path, edit)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		// Empty file
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config %s is not a YAML mapping", path)
+	}
+	if err := edit(root); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if updatectl.ConfigFormat(path) == "json" {
+		if err := writeJSONNode(&buf, root, ""); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	} else {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return err
+		}
+		enc.Close()
+	}
+	return updatectl.WriteFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// editTOMLConfigFile is editConfigFile for a TOML config: the parsed config
+// is turned into a YAML node tree for edit, then written back as TOML.
+func editTOMLConfigFile(path string, edit func(root *yaml.Node) error) error {
+	config, err := updatectl.ReadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	var root yaml.Node
+	if err := root.Encode(config); err != nil {
+		return err
+	}
+	if err := edit(&root); err != nil {
+		return err
+	}
+	config = updatectl.Config{}
+	if err := root.Decode(&config); err != nil {
+		return err
+	}
+	data, err := encodeTOML(config)
+	if err != nil {
+		return err
+	}
+	return updatectl.WriteFileAtomic(path, data, 0644)
+}
+
+// encodeTOML writes config as TOML, in the order of the Config fields.
+func encodeTOML(config updatectl.Config) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(config); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeJSONNode writes n as JSON indented by two spaces, keeping the key
+// order of mappings.
+func writeJSONNode(buf *bytes.Buffer, n *yaml.Node, indent string) error {
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, close := "[", "]"
+		step := 1
+		if n.Kind == yaml.MappingNode {
+			open, close, step = "{", "}", 2
+		}
+		if len(n.Content) == 0 {
+			buf.WriteString(open + close)
+			return nil
+		}
+		buf.WriteString(open)
+		for i := 0; i < len(n.Content); i += step {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + indent + "  ")
+			if n.Kind == yaml.MappingNode {
+				writeJSONString(buf, n.Content[i].Value)
+				buf.WriteString(": ")
+			}
+			if err := writeJSONNode(buf, n.Content[i+step-1], indent+"  "); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + close)
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!int", "!!float", "!!bool":
+			buf.WriteString(n.Value)
+		case "!!null":
+			buf.WriteString("null")
+		default:
+			writeJSONString(buf, n.Value)
+		}
+	case yaml.AliasNode:
+		return writeJSONNode(buf, n.Alias, indent)
+	default:
+		return fmt.Errorf("cannot write YAML node kind %d as JSON", n.Kind)
+	}
+	return nil
+}
+
+// writeJSONString writes s as a JSON string. Unlike json.Marshal it leaves
+// <, > and & alone, so commands such as "npm ci && npm run build" read the
+// same in the file as they did before it was edited.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	buf.Truncate(buf.Len() - 1) // Encode's newline
+}
+
+// yamlToJSON converts a YAML document to indented JSON, keeping key order.
+// Comments are dropped.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeJSONNode(&buf, doc.Content[0], ""); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// yamlToTOML converts a YAML config to TOML. Comments are dropped and keys
+// follow the order of the Config fields.
+func yamlToTOML(data []byte) ([]byte, error) {
+	var config updatectl.Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return encodeTOML(config)
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// projectsNode returns the projects sequence of the root mapping, adding an
+// empty one if the config has none.
+func projectsNode(root *yaml.Node) (*yaml.Node, error) {
+	seq := mappingValue(root, "projects")
+	if seq == nil || seq.Tag == "!!null" {
+		if seq == nil {
+			seq = &yaml.Node{}
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "projects"}, seq)
+		}
+		*seq = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", HeadComment: seq.HeadComment, LineComment: seq.LineComment}
+	}
+	if seq.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("projects is not a list")
+	}
+	return seq, nil
+}
+
+// appendProject adds p to the end of the projects list.
+func appendProject(root *yaml.Node, p updatectl.Project) error {
+	seq, err := projectsNode(root)
+	if err != nil {
+		return err
+	}
+	var n yaml.Node
+	if err := n.Encode(p); err != nil {
+		return err
+	}
+	seq.Content = append(seq.Content, &n)
+	return nil
+}
+
+// deleteProject removes the project called name, along with any comments
+// attached to it, from the projects list.
+func deleteProject(root *yaml.Node, name string) error {
+	seq, err := projectsNode(root)
+	if err != nil {
+		return err
+	}
+	for i, n := range seq.Content {
+		if v := mappingValue(n, "name"); v != nil && v.Value == name {
+			seq.Content = append(seq.Content[:i], seq.Content[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("project %s not found in configuration", name)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEditConfigFileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name, file, config string
+	}{
+		{"yaml", "updatectl.yaml", `# updatectl config for the staging host.
+# Edited by hand; keep the comments.
+
+intervalMinutes: 10 # check every ten minutes
+projects:
+  # The public site.
+  - name: site
+    path: /srv/site
+    repo: https://github.com/example/site.git
+    type: static # served by nginx
+    buildCommand: npm ci && npm run build
+  - name: api # the backend
+    path: /srv/api
+    repo: https://github.com/example/api.git
+    type: docker
+# Nothing below here is read.
+`},
+		{"json", "updatectl.json", `{
+  "intervalMinutes": 10,
+  "projects": [
+    {
+      "name": "site",
+      "path": "/srv/site",
+      "repo": "https://github.com/example/site.git",
+      "type": "static",
+      "buildCommand": "npm ci && npm run build"
+    }
+  ]
+}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			added := updatectl.Project{Name: "worker", Path: "/srv/worker", Repo: "https://github.com/example/worker.git", Type: "docker"}
+			if err := editConfigFile(path, func(root *yaml.Node) error { return appendProject(root, added) }); err != nil {
+				t.Fatalf("appendProject: %v", err)
+			}
+			config, err := updatectl.ReadConfigFile(path)
+			if err != nil {
+				t.Fatalf("reading the config after appendProject: %v", err)
+			}
+			if n := len(config.Projects); n == 0 || config.Projects[n-1].Name != "worker" {
+				t.Fatalf("projects after appendProject = %+v, want worker last", config.Projects)
+			}
+			appended, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for line := range strings.Lines(tt.config) {
+				if strings.Contains(line, "#") && !strings.Contains(string(appended), line) {
+					t.Errorf("line %q lost by appendProject:\n%s", line, appended)
+				}
+			}
+
+			if err := editConfigFile(path, func(root *yaml.Node) error { return deleteProject(root, "worker") }); err != nil {
+				t.Fatalf("deleteProject: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.config {
+				t.Errorf("config after adding and removing a project:\n%s\nwant it unchanged:\n%s", got, tt.config)
+			}
+		})
+	}
+}
+
+func TestEditConfigFileTOML(t *testing.T) {
+	data, err := yamlToTOML([]byte(starterConfig))
+	if err != nil {
+		t.Fatalf("yamlToTOML: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "updatectl.toml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := updatectl.DecodeConfig("updatectl.yaml", []byte(starterConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := updatectl.ReadConfigFile(path); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("starter config as TOML reads as %+v, %v; want %+v\n%s", got, err, want, data)
+	}
+
+	added := updatectl.Project{Name: "worker", Path: "/srv/worker", Type: "docker", BuildCommand: updatectl.Commands{"make", "make install"}}
+	if err := editConfigFile(path, func(root *yaml.Node) error { return appendProject(root, added) }); err != nil {
+		t.Fatalf("appendProject: %v", err)
+	}
+	got, err := updatectl.ReadConfigFile(path)
+	if err != nil {
+		t.Fatalf("reading the config after appendProject: %v", err)
+	}
+	if wantProjects := append(slices.Clone(want.Projects), added); !reflect.DeepEqual(got.Projects, wantProjects) {
+		t.Errorf("projects after appendProject = %+v, want %+v", got.Projects, wantProjects)
+	}
+
+	if err := editConfigFile(path, func(root *yaml.Node) error { return deleteProject(root, "worker") }); err != nil {
+		t.Fatalf("deleteProject: %v", err)
+	}
+	if got, err := updatectl.ReadConfigFile(path); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("config after adding and removing a project = %+v, %v; want %+v", got, err, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the config, required tools and permissions",
+	Long: `Check that the config is valid, that the state directory (the config
+directory, or the user cache directory if that isn't writable), which holds the
+state file, locks and logs, is writable, and that every external tool the
+configured projects need is on PATH and runs. Exits non-zero if any check fails.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := updatectl.ResolveConfigPath()
+		failed := false
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+		report := func(check, detail string, err error) {
+			status := "ok"
+			if err != nil {
+				status, detail, failed = "FAIL", err.Error(), true
+			}
+			// Config errors list one problem per line; keep them in the
+			// detail column.
+			lines := strings.Split(detail, "\n")
+			fmt.Fprintf(w, "%s\t%s\t%s\n", check, status, lines[0])
+			for _, line := range lines[1:] {
+				fmt.Fprintf(w, "\t\t%s\n", line)
+			}
+		}
+
+		config, configErr := updatectl.LoadConfig(path)
+		report("config", path, configErr)
+		stateDir := updatectl.StateDir()
+		if stateDir != filepath.Dir(path) {
+			// The fallback is created on first use, like the config dir by init.
+			os.MkdirAll(stateDir, 0755)
+		}
+		report("state directory writable", stateDirDetail(), checkWritable(stateDir))
+
+		if configErr == nil {
+			// Each tool is checked once, listing the projects that need it.
+			var tools []updatectl.Tool
+			users := make(map[string][]string)
+			for _, p := range config.Projects {
+				for _, t := range updatectl.RequiredTools(config, p) {
+					if _, ok := users[t.Name]; !ok {
+						tools = append(tools, t)
+					}
+					if names := users[t.Name]; len(names) == 0 || names[len(names)-1] != p.Name {
+						users[t.Name] = append(names, p.Name)
+					}
+				}
+			}
+			for _, t := range tools {
+				version, err := updatectl.CheckTool(cmd.Context(), t)
+				needed := " (needed by " + strings.Join(users[t.Name], ", ") + ")"
+				if err != nil {
+					err = fmt.Errorf("%w%s", err, needed)
+				}
+				report(t.Name, version+needed, err)
+			}
+		}
+		w.Flush()
+
+		if failed {
+			os.Exit(1)
+		}
+		fmt.Println("\n✓ All checks passed")
+	},
+}
+
+// checkWritable returns an error unless a file can be created in dir.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".updatectl-doctor-*")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s does not exist (run updatectl init)", dir)
+		}
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+// editErrorMarker starts the comment block edit puts above an invalid config
+// when it reopens the editor. It is stripped again before the file is saved.
+const editErrorMarker = "# updatectl: the config is invalid, fix it or save it unchanged to cancel:"
+
+var editCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config in an editor and validate it before saving",
+	Long: `Open a copy of the config file in $VISUAL or $EDITOR (vi, or notepad on
+Windows). When the editor exits the copy is validated. If it is invalid the
+editor is opened again with the errors at the top; saving it unchanged cancels
+the edit. A valid config replaces the original atomically.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := updatectl.ResolveConfigPath()
+		original, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error: failed to read config: %v\n", err)
+			os.Exit(1)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		// The copy may hold tokens, so it gets CreateTemp's 0600 permissions.
+		tmp, err := os.CreateTemp("", "updatectl-*"+filepath.Ext(path))
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		defer os.Remove(tmp.Name())
+		tmp.Close()
+
+		data := original
+		for {
+			if err := os.WriteFile(tmp.Name(), data, 0600); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			if err := runEditor(tmp.Name()); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			edited, err := os.ReadFile(tmp.Name())
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			if bytes.Equal(edited, data) {
+				fmt.Println("No changes made")
+				return
+			}
+
+			edited = stripEditErrors(edited)
+			verr := validateConfigData(path, edited)
+			if verr == nil {
+				if bytes.Equal(edited, original) {
+					fmt.Println("No changes made")
+					return
+				}
+				if err := updatectl.WriteFileAtomic(path, edited, info.Mode().Perm()); err != nil {
+					fmt.Println("Failed to write config:", err)
+					os.Exit(1)
+				}
+				fmt.Println("✓ Saved", path)
+				if _, err := readPIDFile(); err == nil {
+					fmt.Println("Run 'updatectl reload' to apply it to the running daemon.")
+				}
+				return
+			}
+			data = withEditErrors(edited, verr)
+		}
+	},
+}
+
+// runEditor opens path in the user's editor and waits for it to exit. The
+// editor setting may include arguments, such as "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	args := strings.Fields(editor)
+	c := exec.Command(args[0], append(args[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// validateConfigData parses and validates the contents of the config at path.
+func validateConfigData(path string, data []byte) error {
+	c, err := updatectl.DecodeConfig(path, data)
+	if err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// withEditErrors puts err as a comment block above data.
+func withEditErrors(data []byte, err error) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(editErrorMarker + "\n")
+	for line := range strings.SplitSeq(err.Error(), "\n") {
+		buf.WriteString("# " + line + "\n")
+	}
+	buf.WriteString("#\n")
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// stripEditErrors removes the block added by withEditErrors, if the user left
+// it in place.
+func stripEditErrors(data []byte) []byte {
+	if !bytes.HasPrefix(data, []byte(editErrorMarker+"\n")) {
+		return data
+	}
+	rest := data[len(editErrorMarker)+1:]
+	for bytes.HasPrefix(rest, []byte("#")) {
+		line, after, _ := bytes.Cut(rest, []byte("\n"))
+		rest = after
+		if string(line) == "#" {
+			break
+		}
+	}
+	return rest
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// daemonExecutable returns the absolute path of the running updatectl binary,
+// for the service that init installs to start. A binary built by go run is
+// refused, since it lives in a temporary directory that is removed on exit.
+func daemonExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("could not determine the path of the updatectl binary: %w", err)
+	}
+	if exe, err = filepath.Abs(exe); err != nil {
+		return "", fmt.Errorf("could not determine the path of the updatectl binary: %w", err)
+	}
+	if strings.Contains(exe, string(filepath.Separator)+"go-build") {
+		return "", fmt.Errorf("%s is a temporary go run build; install updatectl and run init from the installed binary", exe)
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return "", fmt.Errorf("updatectl binary not found: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("updatectl binary %s is not a regular file", exe)
+	}
+	return exe, nil
+}
+
+// systemdQuote quotes s for an ExecStart line if it contains characters
+// systemd would otherwise split on or unescape.
+func systemdQuote(s string) string {
+	if strings.ContainsAny(s, " \t\"'\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+// projectFilter restricts which configured projects a command works on, so
+// machines sharing a config can split the projects between them. The zero
+// value matches every project.
+type projectFilter struct {
+	types  []string // project types to keep
+	only   []string // name patterns to keep
+	except []string // name patterns to drop
+}
+
+// addProjectFilterFlags registers --type on cmd and, if names is set, the
+// --only and --except name filters.
+func addProjectFilterFlags(cmd *cobra.Command, names bool) {
+	cmd.Flags().StringSlice("type", nil, "Only include projects of these types (repeatable or comma-separated)")
+	if names {
+		cmd.Flags().StringSlice("only", nil, "Only include projects whose names match these patterns")
+		cmd.Flags().StringSlice("except", nil, "Exclude projects whose names match these patterns")
+	}
+}
+
+// projectFilterFromFlags reads the flags registered by addProjectFilterFlags,
+// rejecting unknown types and malformed patterns.
+func projectFilterFromFlags(cmd *cobra.Command) (projectFilter, error) {
+	var f projectFilter
+	f.types, _ = cmd.Flags().GetStringSlice("type")
+	f.only, _ = cmd.Flags().GetStringSlice("only")
+	f.except, _ = cmd.Flags().GetStringSlice("except")
+
+	for _, t := range f.types {
+		if !slices.Contains(updatectl.ProjectTypes, t) {
+			return f, fmt.Errorf("unknown project type %q (must be one of %s)", t, strings.Join(updatectl.ProjectTypes, ", "))
+		}
+	}
+	for _, pattern := range append(slices.Clone(f.only), f.except...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return f, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return f, nil
+}
+
+// apply returns the projects that pass the filter, in config order.
+func (f projectFilter) apply(projects []updatectl.Project) []updatectl.Project {
+	var kept []updatectl.Project
+	for _, p := range projects {
+		if len(f.types) > 0 && !slices.Contains(f.types, p.Type) {
+			continue
+		}
+		if len(f.only) > 0 && !matchesAny(f.only, p.Name) {
+			continue
+		}
+		if matchesAny(f.except, p.Name) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// matchesAny reports whether name matches any of patterns. The patterns are
+// assumed to be valid, as checked by projectFilterFromFlags.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [project-name]",
+	Short: "Show recent deploys",
+	Long: `Show the most recent deploys recorded in history.jsonl in the state directory,
+newest last: updates by watch and once, and builds run with the build command.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		limit, _ := cmd.Flags().GetInt("limit")
+		var project string
+		if len(args) == 1 {
+			project = args[0]
+		}
+
+		events, err := updatectl.ReadHistory(project, limit)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if asJSON {
+			if events == nil {
+				events = []updatectl.HistoryEvent{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(events)
+			return
+		}
+		if len(events) == 0 {
+			fmt.Println("No deploys recorded yet.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tPROJECT\tTRIGGER\tFROM\tTO\tDURATION\tRESULT\tERROR")
+		for _, ev := range events {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				ev.Time.Local().Format("2006-01-02 15:04:05"), ev.Project, ev.Trigger,
+				orDash(shortRef(ev.From)), orDash(shortRef(ev.To)),
+				(time.Duration(ev.Duration * float64(time.Second))).Round(time.Second),
+				ev.Result, orDash(ev.Error))
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	historyCmd.Flags().IntP("limit", "n", 20, "Number of deploys to show (0 for all)")
+	historyCmd.Flags().Bool("json", false, "Output deploys as JSON")
+}
+
+// shortRef abbreviates a commit hash or image digest to 7 characters.
+func shortRef(ref string) string {
+	if _, digest, ok := strings.Cut(ref, "@"); ok {
+		ref = digest
+	}
+	ref = strings.TrimPrefix(ref, "sha256:")
+	if len(ref) > 7 {
+		return ref[:7]
+	}
+	return ref
+}
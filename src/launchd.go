@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const launchdLabel = "com.parcoil.updatectl"
+
+// launchAgentPath returns where the updatectl launch agent plist is installed.
+func launchAgentPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist")
+}
+
+// installLaunchAgent writes a launchd plist that keeps `updatectl watch`
+// running for the current user, using the binary at exe, and loads it. In a
+// dry run it prints the plist and the command instead.
+func installLaunchAgent(exe, configPath string) error {
+	plistPath := launchAgentPath()
+	plist := launchAgentPlist(exe, configPath)
+	domain := fmt.Sprintf("gui/%d", os.Getuid())
+	if options.DryRun {
+		printWouldWrite(plistPath, []byte(plist))
+		printWouldRun("launchctl", "bootstrap", domain, plistPath)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return fmt.Errorf("failed to create LaunchAgents directory: %w", err)
+	}
+	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
+		return fmt.Errorf("failed to write launchd plist: %w", err)
+	}
+	fmt.Println("Created launchd plist at", plistPath)
+
+	if output, err := exec.Command("launchctl", "bootstrap", domain, plistPath).CombinedOutput(); err != nil {
+		// Older macOS releases only support the legacy load subcommand.
+		if legacyOutput, legacyErr := exec.Command("launchctl", "load", "-w", plistPath).CombinedOutput(); legacyErr != nil {
+			return fmt.Errorf("failed to load launch agent: %v\nOutput: %s%s", legacyErr, output, legacyOutput)
+		}
+	}
+	fmt.Println("Launch agent loaded and started.")
+	fmt.Printf("\nUnload it with: launchctl bootout %s %s\n", domain, plistPath)
+	fmt.Println("View logs with: updatectl logs -f")
+	return nil
+}
+
+// launchAgentPlist returns the launchd plist that keeps exe's watch running
+// with the config at configPath.
+func launchAgentPlist(exe, configPath string) string {
+	// The daemon writes and rotates updatectl.log itself; launchd only
+	// captures anything printed outside the logger, such as a crash.
+	logPath := filepath.Join(filepath.Dir(configPath), "updatectl.log")
+	outPath := filepath.Join(filepath.Dir(configPath), "updatectl.out.log")
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>watch</string>
+		<string>--config</string>
+		<string>%s</string>
+		<string>--log-file</string>
+		<string>%s</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, launchdLabel, exe, configPath, logPath, outPath, outPath)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+)
+
+// filterByLastUpdate keeps the projects whose last successful update, as
+// recorded in state, is older than stale and within updatedSince. A zero
+// duration disables that bound. Projects never updated count as stale.
+func filterByLastUpdate(projects []updatectl.Project, state updatectl.State, stale, updatedSince time.Duration, now time.Time) []updatectl.Project {
+	var kept []updatectl.Project
+	for _, p := range projects {
+		last := state.Projects[p.Name].LastUpdate
+		if stale > 0 && !last.IsZero() && now.Sub(last) < stale {
+			continue
+		}
+		if updatedSince > 0 && (last.IsZero() || now.Sub(last) > updatedSince) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// lastUpdateAge describes how long ago last was, e.g. "updated 3h ago".
+func lastUpdateAge(last, now time.Time) string {
+	if last.IsZero() {
+		return "never updated"
+	}
+	d := now.Sub(last)
+	switch {
+	case d < time.Minute:
+		return "updated just now"
+	case d < time.Hour:
+		return fmt.Sprintf("updated %dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("updated %dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("updated %dd ago", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// listFormats are the named presets accepted by list --format. Each has a
+// header line printed once before the rows.
+var listFormats = map[string]struct{ header, row string }{
+	"names": {"", `{{.Name}}`},
+	"wide": {
+		"NAME\tTYPE\tSOURCE\tBRANCH\tPATH\tCHECK",
+		`{{.Name}}\t{{.Type}}\t{{if .Image}}{{.Image}}{{else}}{{or .Repo "-"}}{{end}}\t{{or .Branch "-"}}\t{{or .Path "-"}}\t{{if .Cron}}{{.Cron}}{{else}}every {{.Interval}}s{{end}}`,
+	},
+}
+
+// listTemplate parses a list --format value: a preset name or a text/template
+// evaluated against each projectListing. Tabs in the output are aligned into
+// columns, and "\t" may be written literally on the command line.
+func listTemplate(format string) (header string, tmpl *template.Template, err error) {
+	row := format
+	if preset, ok := listFormats[format]; ok {
+		header, row = preset.header, preset.row
+	}
+	row = strings.ReplaceAll(row, `\t`, "\t")
+	tmpl, err = template.New("format").Funcs(template.FuncMap{"join": strings.Join}).Parse(row)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	return header, tmpl, nil
+}
+
+// printListings writes one line per listing using a list --format template.
+func printListings(out io.Writer, format string, listings []projectListing) error {
+	header, tmpl, err := listTemplate(format)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if header != "" {
+		fmt.Fprintln(w, header)
+	}
+	for _, l := range listings {
+		if err := tmpl.Execute(w, l); err != nil {
+			return fmt.Errorf("--format template failed for %s: %w", l.Name, err)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
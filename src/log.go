@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+)
+
+var (
+	logLevel string
+	logFile  string
+)
+
+// setupLogging validates the --log-level and --log-format flags and
+// configures the updatectl package logger accordingly.
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", logLevel)
+	}
+	if updatectl.LogFormat != "text" && updatectl.LogFormat != "json" {
+		return fmt.Errorf("invalid log format %q (expected text or json)", updatectl.LogFormat)
+	}
+	if options.Verbose && updatectl.Quiet {
+		return fmt.Errorf("--verbose and --quiet can't be used together")
+	}
+	if updatectl.Quiet && level < slog.LevelError {
+		level = slog.LevelError
+	}
+	updatectl.LogLevel.Set(level)
+	if logFile != "" {
+		f, err := openRotatingFile(logFile)
+		if err != nil {
+			return err
+		}
+		updatectl.Output = f
+	}
+	updatectl.Logger = updatectl.NewLogger(updatectl.Output)
+	return nil
+}
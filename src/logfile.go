@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+)
+
+const (
+	// logFileMaxSize is the size at which the log file is rotated.
+	logFileMaxSize = 10 << 20
+	// logFileBackups is how many rotated files (updatectl.log.1 ...) are kept.
+	logFileBackups = 3
+)
+
+// defaultLogFilePath returns where the daemon log file lives when --log-file
+// is not given: updatectl.log in the state directory.
+func defaultLogFilePath() string {
+	return filepath.Join(updatectl.StateDir(), "updatectl.log")
+}
+
+// rotatingFile is an io.Writer that appends to a file and rotates it once it
+// grows past logFileMaxSize, keeping logFileBackups old copies.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	r := &rotatingFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > logFileMaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts updatectl.log to updatectl.log.1, .1 to .2 and so on,
+// dropping the oldest, then starts a new empty file.
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	for i := logFileBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return r.open()
+}
+
+// tailFile writes the last n lines of path to w. If follow is set it keeps
+// writing new lines as they are appended, reopening the file when it is
+// rotated, until ctx is cancelled.
+func tailFile(ctx context.Context, path string, n int, follow bool, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	w.Write(lastLines(data, n))
+	if !follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if _, err := io.Copy(w, f); err != nil {
+			return err
+		}
+		current, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		latest, err := os.Stat(path)
+		if err != nil || os.SameFile(current, latest) {
+			// Mid-rotation, or nothing changed: check again on the next tick.
+			continue
+		}
+		rotated, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		f.Close()
+		f = rotated
+	}
+}
+
+// lastLines returns the final n lines of data.
+func lastLines(data []byte, n int) []byte {
+	if n <= 0 {
+		return nil
+	}
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	start := end
+	for i := 0; i < n; i++ {
+		j := bytes.LastIndexByte(data[:start], '\n')
+		if j < 0 {
+			return data
+		}
+		start = j
+	}
+	return data[start+1:]
+}
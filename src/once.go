@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+// interactive is set by once --interactive: each project's incoming commits
+// are shown and the update only goes ahead once confirmed on stdin.
+var interactive bool
+
+var onceCmd = &cobra.Command{
+	Use:   "once [project-name...]",
+	Short: "Run a single update cycle and exit",
+	Long:  "Run one update pass over all configured projects (or only the named ones) and exit non-zero if any project failed.",
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, err := projectFilterFromFlags(cmd)
+		if err != nil {
+			updatectl.Logger.Error(err.Error())
+			os.Exit(1)
+		}
+		jsonOutput, _, err := jsonOutputFromFlags(cmd)
+		if err == nil && jsonOutput && interactive {
+			err = errors.New("--interactive can't be combined with --output json")
+		}
+		if err != nil {
+			updatectl.Logger.Error(err.Error())
+			os.Exit(1)
+		}
+		config, err := updatectl.LoadConfig(updatectl.ResolveConfigPath())
+		if err != nil {
+			updatectl.Logger.Error("Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		updatectl.WarnIfShellMissing(config)
+
+		if show, _ := cmd.Flags().GetBool("show-changes"); show {
+			config.ShowChanges = true
+		}
+		if interactive {
+			options.Confirm = confirm
+			// One project at a time, so prompts don't interleave.
+			config.Concurrency = 1
+		}
+
+		if len(args) > 0 {
+			projects, err := selectProjects(config.Projects, args)
+			if err != nil {
+				updatectl.Logger.Error(err.Error())
+				os.Exit(1)
+			}
+			config.Projects = projects
+		}
+		config.Projects = filter.apply(config.Projects)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		// The timeout covers the whole pass, git included, unlike the
+		// per-build buildTimeoutSeconds.
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		lockTimeout, _ := cmd.Flags().GetDuration("lock-timeout")
+		unlock, err := lockInstance(ctx, lockTimeout)
+		if err != nil {
+			updatectl.Logger.Error(err.Error())
+			os.Exit(1)
+		}
+		defer unlock()
+
+		results, err := updatectl.NewUpdater(options).RunCycleResults(ctx, config)
+		if jsonOutput {
+			printJSONSummary(results)
+		} else if !updatectl.Quiet && !options.DryRun && updatectl.LogFormat == "text" {
+			printCycleSummary(results)
+		}
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				updatectl.Logger.Error("Update cycle timed out", "timeout", timeout, "unfinished", strings.Join(updatectl.UnfinishedProjects(err), ","))
+			}
+			updatectl.Logger.Error("Update cycle failed", "error", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	addProjectFilterFlags(onceCmd, true)
+	addOutputFlag(onceCmd)
+	onceCmd.Flags().Duration("timeout", 0, "Cancel the update cycle and fail if it takes longer than this (e.g. 10m; default no limit)")
+	onceCmd.Flags().Duration("lock-timeout", 0, "How long to wait while another updatectl runs against the same config (default: fail at once)")
+	onceCmd.Flags().Bool("show-changes", false, "Print the incoming commits of each project before updating it")
+	onceCmd.Flags().BoolVar(&options.IgnoreSchedule, "ignore-schedule", false, "Deploy even outside the configured maintenance window")
+	onceCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Show incoming commits and ask before updating each project")
+}
+
+// printCycleSummary prints one line per project saying whether the cycle
+// deployed it, found nothing to do or failed.
+func printCycleSummary(results []updatectl.ProjectResult) {
+	if len(results) == 0 {
+		return
+	}
+	state, _ := updatectl.LoadState()
+	fmt.Println()
+	for _, r := range results {
+		switch {
+		case r.Result == "failed":
+			fmt.Printf("%s %s %s\n", markFailed(), r.Name, red("failed: "+r.Error))
+		case r.Result == "updated":
+			fmt.Printf("%s %s %s\n", markOK(), r.Name, green("updated"))
+		case state.Projects[r.Name].Pending != "":
+			fmt.Printf("%s %s %s\n", markNeutral(), r.Name, yellow("waiting for the maintenance window"))
+		default:
+			fmt.Printf("%s %s %s\n", markNeutral(), r.Name, yellow("up to date"))
+		}
+	}
+}
+
+// selectProjects returns the projects with the given names, in config order.
+func selectProjects(projects []updatectl.Project, names []string) ([]updatectl.Project, error) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var selected []updatectl.Project
+	for _, p := range projects {
+		if wanted[p.Name] {
+			selected = append(selected, p)
+			delete(wanted, p.Name)
+		}
+	}
+	for _, name := range names {
+		if wanted[name] {
+			return nil, fmt.Errorf("project %s not found in configuration", name)
+		}
+	}
+	return selected, nil
+}
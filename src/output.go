@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+// addOutputFlag adds --output to a command that can end with a JSON summary.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().String("output", "text", "Output format: text, or json for a summary on stdout with everything else on stderr")
+}
+
+// jsonOutputFromFlags reports whether --output json was given. In that case
+// logs, unless they go to a log file, are moved to stderr and colors are
+// turned off, so that stdout carries nothing but the summary. It returns the
+// writer for the command's own messages.
+func jsonOutputFromFlags(cmd *cobra.Command) (bool, io.Writer, error) {
+	switch output, _ := cmd.Flags().GetString("output"); output {
+	case "text":
+		return false, os.Stdout, nil
+	case "json":
+	default:
+		return false, os.Stdout, fmt.Errorf("invalid --output %q (expected text or json)", output)
+	}
+	if logFile == "" {
+		updatectl.Output = os.Stderr
+		updatectl.Logger = updatectl.NewLogger(updatectl.Output)
+	}
+	colorOutput = false
+	return true, os.Stderr, nil
+}
+
+// printJSONSummary writes the per-project results of once or build to stdout
+// as {"projects": [...]}.
+func printJSONSummary(results []updatectl.ProjectResult) {
+	if results == nil {
+		results = []updatectl.ProjectResult{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(struct {
+		Projects []updatectl.ProjectResult `json:"projects"`
+	}{results})
+}
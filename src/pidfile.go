@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+)
+
+// pidFilePath returns where the watch daemon records its PID: updatectl.pid
+// in the state directory.
+func pidFilePath() string {
+	return filepath.Join(updatectl.StateDir(), "updatectl.pid")
+}
+
+// runningDaemonPID returns the PID of the running watch daemon, if the PID
+// file names a live process other than this one.
+func runningDaemonPID() (int, bool) {
+	pid, err := readPIDFile()
+	if err != nil || pid == os.Getpid() || !processAlive(pid) {
+		return 0, false
+	}
+	return pid, true
+}
+
+// writePIDFile records the current process as the running daemon.
+func writePIDFile() error {
+	path := pidFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create PID file directory: %w", err)
+	}
+	return updatectl.WriteFileAtomic(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// removePIDFile deletes the PID file if it still belongs to this process.
+func removePIDFile() {
+	if pid, err := readPIDFile(); err == nil && pid == os.Getpid() {
+		os.Remove(pidFilePath())
+	}
+}
+
+// readPIDFile returns the PID recorded by the watch daemon.
+func readPIDFile() (int, error) {
+	data, err := os.ReadFile(pidFilePath())
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid PID file %s", pidFilePath())
+	}
+	return pid, nil
+}
+
+// lockInstance takes the config's instance lock for a command that changes
+// projects, waiting up to timeout if another updatectl holds it. Dry runs
+// change nothing and don't take it. The returned function releases the lock.
+func lockInstance(ctx context.Context, timeout time.Duration) (func(), error) {
+	if options.DryRun {
+		return func() {}, nil
+	}
+	unlock, err := updatectl.LockInstance(ctx, 0)
+	if errors.Is(err, updatectl.ErrInstanceLocked) && timeout > 0 {
+		updatectl.Logger.Info("Another updatectl is running against this config, waiting", "timeout", timeout)
+		unlock, err = updatectl.LockInstance(ctx, timeout)
+	}
+	if errors.Is(err, updatectl.ErrInstanceLocked) {
+		return nil, fmt.Errorf("%w (lock file %s)", err, updatectl.InstanceLockPath())
+	}
+	return unlock, err
+}
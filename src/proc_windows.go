@@ -0,0 +1,19 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// triggerSignal is nil: Windows has no SIGUSR1, so a running daemon can't be
+// triggered.
+var triggerSignal os.Signal
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the running daemon re-read its config",
+	Long: `Send SIGHUP to the running watch daemon so it re-reads the config file.
+Updates already in progress finish with the old config; the next cycle uses
+the new one. If the new config is invalid the daemon keeps the old one.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if runtime.GOOS == "windows" {
+			fmt.Println("Error: reload is not supported on Windows, restart the scheduled task instead.")
+			os.Exit(1)
+		}
+
+		pid := signalDaemon(syscall.SIGHUP)
+		fmt.Printf("Sent reload signal to daemon (PID %d)\n", pid)
+	},
+}
+
+// signalDaemon sends sig to the watch daemon named by the PID file and
+// returns its PID, exiting with an error if it isn't running.
+func signalDaemon(sig os.Signal) int {
+	pid, err := readPIDFile()
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Println("Error: the daemon is not running (no PID file at", pidFilePath()+")")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if !processAlive(pid) {
+		fmt.Printf("Error: the daemon is not running (stale PID file %s names PID %d)\n", pidFilePath(), pid)
+		os.Exit(1)
+	}
+
+	process, err := os.FindProcess(pid)
+	if err == nil {
+		err = process.Signal(sig)
+	}
+	if err != nil {
+		fmt.Printf("Error: failed to signal daemon (PID %d): %v\n", pid, err)
+		os.Exit(1)
+	}
+	return pid
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var removeCmd = &cobra.Command{
+	Use:   "remove [project-name]",
+	Short: "Remove a project from the config",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		purgePath, _ := cmd.Flags().GetBool("purge-path")
+		yes, _ := cmd.Flags().GetBool("yes")
+		name := args[0]
+
+		path := updatectl.ResolveConfigPath()
+		config, err := updatectl.ReadConfigFile(path)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		index := -1
+		for i, p := range config.Projects {
+			if p.Name == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			fmt.Printf("Error: project %s not found in configuration\n", name)
+			os.Exit(1)
+		}
+		removed := config.Projects[index]
+		config.Projects = append(config.Projects[:index], config.Projects[index+1:]...)
+
+		if err := editConfigFile(path, func(root *yaml.Node) error { return deleteProject(root, name) }); err != nil {
+			fmt.Println("Failed to write config:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed project %s from %s\n", name, path)
+
+		if purgePath && removed.Path != "" {
+			if yes || confirm(fmt.Sprintf("Also delete %s?", removed.Path)) {
+				if err := os.RemoveAll(removed.Path); err != nil {
+					fmt.Printf("Failed to remove %s: %v\n", removed.Path, err)
+				} else {
+					fmt.Println("Deleted", removed.Path)
+				}
+			}
+		}
+
+		fmt.Printf("%d project(s) remaining\n", len(config.Projects))
+	},
+}
+
+func init() {
+	removeCmd.Flags().Bool("purge-path", false, "Also delete the project's checked-out directory")
+	removeCmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+}
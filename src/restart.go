@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+var restartCmd = &cobra.Command{
+	Use:   "restart [project-name]",
+	Short: "Restart a project without pulling or rebuilding",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := updatectl.LoadConfig(updatectl.ResolveConfigPath())
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		projects, err := selectProjects(config.Projects, args)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		p := projects[0]
+
+		if options.DryRun {
+			fmt.Printf("Would restart project %s (type %s)\n", p.Name, p.Type)
+			return
+		}
+		if !updatectl.Quiet {
+			fmt.Printf("Restarting project %s...\n", p.Name)
+		}
+		if err := updatectl.NewUpdater(options).RestartProject(cmd.Context(), p, updatectl.Logger.With("project", p.Name), updatectl.CommandOutput(os.Stdout)); err != nil {
+			fmt.Printf("Restart failed for %s: %v\n", p.Name, err)
+			os.Exit(1)
+		}
+		if !updatectl.Quiet {
+			fmt.Printf("Restart completed for %s\n", p.Name)
+		}
+	},
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package main
+
+import "github.com/spf13/cobra"
+
+// serviceCmd is nil: the service command manages a Windows service.
+var serviceCmd *cobra.Command
+
+// runService reports false: only Windows runs updatectl as a service.
+func runService(rootCmd *cobra.Command) bool { return false }
+
+// installWindowsService is never called outside Windows.
+func installWindowsService(exe, path string) error { return nil }
+
+// uninstallWindowsService is never called outside Windows.
+func uninstallWindowsService() []string { return nil }
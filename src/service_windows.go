@@ -0,0 +1,330 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceName is the name of the Windows service, and of its event log
+// source.
+const serviceName = "updatectl"
+
+// serviceStopTimeout is how long stop waits for the service to finish.
+const serviceStopTimeout = 30 * time.Second
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Install, start, stop or remove the updatectl Windows service",
+	Long: `Manage the Windows service that runs 'updatectl watch'.
+
+The service starts automatically at boot, runs as LocalSystem and is
+restarted by the service manager if it crashes. These commands need an
+elevated (Administrator) prompt.`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the service and start it",
+	Run: func(cmd *cobra.Command, args []string) {
+		exe, err := daemonExecutable()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := installWindowsService(exe, updatectl.ResolveConfigPath()); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var serviceStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the service",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := startWindowsService(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Service started.")
+	},
+}
+
+var serviceStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the service",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := stopWindowsService(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Service stopped.")
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the service",
+	Run: func(cmd *cobra.Command, args []string) {
+		removed := uninstallWindowsService()
+		if len(removed) == 0 {
+			fmt.Println("Nothing to remove.")
+			return
+		}
+		fmt.Println("Removed:")
+		for _, r := range removed {
+			fmt.Println("  -", r)
+		}
+	},
+}
+
+func init() {
+	serviceCmd.AddCommand(serviceInstallCmd, serviceStartCmd, serviceStopCmd, serviceUninstallCmd)
+}
+
+// installWindowsService creates the updatectl service, running exe's watch
+// with the config at path, and starts it. An existing service is updated to
+// the new command line. In a dry run it prints the service's command line
+// instead.
+func installWindowsService(exe, path string) error {
+	args := []string{"watch", "--config", path, "--log-file", defaultLogFilePath()}
+	commandLine := windows.EscapeArg(exe)
+	for _, arg := range args {
+		commandLine += " " + windows.EscapeArg(arg)
+	}
+	if options.DryRun {
+		fmt.Printf("Would install Windows service %s (automatic start, LocalSystem) running:\n  %s\n", serviceName, commandLine)
+		fmt.Printf("Would start Windows service %s\n", serviceName)
+		return nil
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager (run as Administrator, or use 'updatectl init --task'): %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err == nil {
+		config, err := s.Config()
+		if err == nil {
+			config.BinaryPathName = commandLine
+			err = s.UpdateConfig(config)
+		}
+		if err != nil {
+			s.Close()
+			return fmt.Errorf("failed to update service %s: %w", serviceName, err)
+		}
+		fmt.Println("Updated Windows service", serviceName)
+	} else {
+		s, err = m.CreateService(serviceName, exe, mgr.Config{
+			DisplayName: "Updatectl",
+			Description: "Updatectl Daemon - Auto-update your projects",
+			StartType:   mgr.StartAutomatic,
+		}, args...)
+		if err != nil {
+			return fmt.Errorf("failed to create service %s: %w", serviceName, err)
+		}
+		fmt.Println("Created Windows service", serviceName)
+	}
+	defer s.Close()
+
+	// Restart a crashed daemon after 10 seconds, forgetting failures after
+	// a day.
+	restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 10 * time.Second}
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, 24*60*60); err != nil {
+		fmt.Println("Failed to set the service's restart-on-failure actions:", err)
+	}
+	eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info) // Ignore error if the source is already registered
+
+	if err := startWindowsService(); err != nil {
+		return err
+	}
+	fmt.Println("Windows service installed and started.")
+	fmt.Println("\nCheck status with: sc query updatectl")
+	fmt.Println("View logs with: updatectl logs -f")
+	return nil
+}
+
+// startWindowsService starts the service unless it is already running.
+func startWindowsService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed; run 'updatectl service install'", serviceName)
+	}
+	defer s.Close()
+	if status, err := s.Query(); err == nil && status.State == svc.Running {
+		return nil
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service %s: %w", serviceName, err)
+	}
+	return nil
+}
+
+// stopWindowsService asks the service to stop and waits for it to exit.
+func stopWindowsService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+	return stopService(s)
+}
+
+// stopService sends s a stop request and waits up to serviceStopTimeout for
+// it to stop. A service that isn't running is left alone.
+func stopService(s *mgr.Service) error {
+	status, err := s.Query()
+	if err != nil {
+		return fmt.Errorf("failed to query service %s: %w", serviceName, err)
+	}
+	if status.State == svc.Stopped {
+		return nil
+	}
+	if status.State != svc.StopPending {
+		if status, err = s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop service %s: %w", serviceName, err)
+		}
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s did not stop within %s", serviceName, serviceStopTimeout)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("failed to query service %s: %w", serviceName, err)
+		}
+	}
+	return nil
+}
+
+// uninstallWindowsService stops and deletes the service and its event log
+// source, returning what was removed. A missing service is not an error.
+func uninstallWindowsService() []string {
+	m, err := mgr.Connect()
+	if err != nil {
+		fmt.Println("Failed to connect to the service manager:", err)
+		return nil
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return nil
+	}
+	defer s.Close()
+
+	var removed []string
+	if err := stopService(s); err != nil {
+		fmt.Println(err)
+	}
+	if err := s.Delete(); err != nil {
+		fmt.Printf("Failed to delete service %s: %v\n", serviceName, err)
+	} else {
+		removed = append(removed, "Windows service "+serviceName)
+	}
+	eventlog.Remove(serviceName) // Ignore error if the source was never registered
+	return removed
+}
+
+// runService runs rootCmd under the service control manager if this
+// process was started as a Windows service, and reports whether it was.
+func runService(rootCmd *cobra.Command) bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false
+	}
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		elog = nil
+	}
+	h := &serviceHandler{root: rootCmd, elog: elog}
+	if err := svc.Run(serviceName, h); err != nil {
+		h.event(eventlog.Error, fmt.Sprintf("updatectl service failed: %v", err))
+		os.Exit(1)
+	}
+	if elog != nil {
+		elog.Close()
+	}
+	return true
+}
+
+// serviceHandler runs the command line the service was installed with
+// (updatectl watch) and cancels it when the service manager asks it to stop.
+type serviceHandler struct {
+	root *cobra.Command
+	elog *eventlog.Log
+}
+
+// Execute implements svc.Handler. Cancelling the command's context stops
+// watch the same way Ctrl+C does: the running cycle's commands are killed
+// and the daemon exits.
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- h.root.ExecuteContext(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	h.event(eventlog.Info, "updatectl service started")
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				h.event(eventlog.Error, fmt.Sprintf("updatectl watch exited: %v", err))
+				return false, 1
+			}
+			h.event(eventlog.Info, "updatectl service stopped")
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(serviceStopTimeout / time.Millisecond)}
+				cancel()
+				<-done
+				h.event(eventlog.Info, "updatectl service stopped")
+				return false, 0
+			}
+		}
+	}
+}
+
+// event writes msg to the Windows event log, if the source is registered.
+func (h *serviceHandler) event(kind uint32, msg string) {
+	if h.elog == nil {
+		return
+	}
+	switch kind {
+	case eventlog.Error:
+		h.elog.Error(1, msg)
+	case eventlog.Warning:
+		h.elog.Warning(1, msg)
+	default:
+		h.elog.Info(1, msg)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show current commit and last update time for each project",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		config, err := updatectl.LoadConfig(updatectl.ResolveConfigPath())
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		state, err := updatectl.LoadState()
+		if err != nil {
+			fmt.Println("⚠", err)
+		}
+
+		statuses := make([]updatectl.ProjectStatus, 0, len(config.Projects))
+		for _, p := range config.Projects {
+			statuses = append(statuses, updatectl.ReadProjectStatus(cmd.Context(), p, state))
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(statuses)
+			return
+		}
+
+		if len(statuses) == 0 {
+			fmt.Println("No projects configured.")
+			return
+		}
+		if pid, running := runningDaemonPID(); running {
+			fmt.Printf("Daemon: running (PID %d)\n", pid)
+		} else {
+			fmt.Println("Daemon: not running")
+		}
+		fmt.Printf("State:  %s\n\n", stateDirDetail())
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTYPE\tBRANCH\tCOMMIT\tDIRTY\tLAST UPDATE\tPENDING\tHEALTH\tLAST ERROR")
+		for _, s := range statuses {
+			lastUpdate := "never"
+			if s.LastUpdate != nil {
+				lastUpdate = s.LastUpdate.Local().Format("2006-01-02 15:04:05")
+			}
+			commit := s.Commit
+			if len(commit) > 7 {
+				commit = commit[:7]
+			}
+			pending := strings.TrimPrefix(s.Pending, "sha256:")
+			if len(pending) > 7 {
+				pending = pending[:7]
+			}
+			lastError := s.LastError
+			if s.LastErrorAt != nil {
+				lastError = fmt.Sprintf("%s (%s)", lastError, s.LastErrorAt.Local().Format("2006-01-02 15:04:05"))
+			}
+			if s.RolledBack {
+				lastError = "rolled back: " + lastError
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\t%s\t%s\t%s\n",
+				s.Name, s.Type, orDash(s.Branch), orDash(commit), s.Dirty, lastUpdate, orDash(pending), orDash(s.Health), orDash(lastError))
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	statusCmd.Flags().Bool("json", false, "Output status as JSON")
+}
+
+// stateDirDetail describes where state is kept, noting when that isn't the
+// config directory because it can't be written to.
+func stateDirDetail() string {
+	dir := updatectl.StateDir()
+	if configDir := filepath.Dir(updatectl.ResolveConfigPath()); dir != configDir {
+		return fmt.Sprintf("%s (config directory %s is not writable)", dir, configDir)
+	}
+	return dir
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var triggerCmd = &cobra.Command{
+	Use:   "trigger",
+	Short: "Make the running daemon check every project now",
+	Long: `Send SIGUSR1 to the running watch daemon so it checks every project right
+away instead of waiting for the next interval or cron time. Afterwards the
+normal schedule resumes. If a cycle is already running, the check starts as
+soon as it finishes.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if triggerSignal == nil {
+			fmt.Println("Error: trigger is not supported on Windows, run updatectl once instead.")
+			os.Exit(1)
+		}
+		pid := signalDaemon(triggerSignal)
+		fmt.Printf("Sent trigger signal to daemon (PID %d)\n", pid)
+	},
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the updatectl daemon (and optionally its config)",
+	Run: func(cmd *cobra.Command, args []string) {
+		purge, _ := cmd.Flags().GetBool("purge")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		// A user-level install from init --user is removed without root.
+		userInstall := runtime.GOOS == "linux" && os.Geteuid() != 0
+		if _, err := os.Stat(systemdUserUnitPath()); userInstall && err != nil {
+			fmt.Println("Error: This command requires root privileges on Linux.")
+			fmt.Println("Please run: sudo updatectl uninstall")
+			os.Exit(1)
+		}
+
+		prompt := "Stop and remove the updatectl daemon?"
+		if purge {
+			prompt = "Stop and remove the updatectl daemon and delete its config, state and logs?"
+		}
+		if !yes && !confirm(prompt) {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		var removed []string
+		switch runtime.GOOS {
+		case "windows":
+			removed = uninstallWindowsService()
+			if windowsTaskExists() {
+				removed = append(removed, uninstallWindowsTask()...)
+			}
+		case "darwin":
+			removed = uninstallLaunchAgent()
+		case "linux":
+			if userInstall {
+				removed = uninstallSystemdUserService()
+			} else {
+				removed = uninstallSystemdService()
+			}
+		default:
+			removed = uninstallSystemdService()
+		}
+
+		if purge {
+			path := updatectl.ResolveConfigPath()
+			// Removing the config may change what the default path resolves
+			// to, so keep the state directory found next to it.
+			updatectl.ConfigPath = path
+			files := []string{path, updatectl.StatePath(), updatectl.StatePath() + ".lock", defaultLogFilePath(), filepath.Join(filepath.Dir(path), "updatectl.out.log")}
+			for i := 1; i <= logFileBackups; i++ {
+				files = append(files, fmt.Sprintf("%s.%d", defaultLogFilePath(), i))
+			}
+			for _, file := range files {
+				if removeFile(file) {
+					removed = append(removed, file)
+				}
+			}
+			for _, dir := range []string{updatectl.LockDir(), filepath.Join(updatectl.StateDir(), "logs")} {
+				if _, err := os.Stat(dir); err == nil && os.RemoveAll(dir) == nil {
+					removed = append(removed, dir)
+				}
+			}
+			// Only remove the config and state dirs if nothing else is left
+			// in them.
+			for _, dir := range []string{updatectl.StateDir(), filepath.Dir(path)} {
+				if os.Remove(dir) == nil {
+					removed = append(removed, dir)
+				}
+			}
+		}
+
+		if len(removed) == 0 {
+			fmt.Println("Nothing to remove.")
+			return
+		}
+		fmt.Println("Removed:")
+		for _, r := range removed {
+			fmt.Println("  -", r)
+		}
+	},
+}
+
+func init() {
+	uninstallCmd.Flags().Bool("purge", false, "Also remove the config, state, log and build log files")
+	uninstallCmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+}
+
+func uninstallSystemdService() []string {
+	var removed []string
+	disableCmd := exec.Command("systemctl", "disable", "--now", "updatectl")
+	if output, err := disableCmd.CombinedOutput(); err != nil {
+		fmt.Printf("Failed to stop and disable service: %v\nOutput: %s\n", err, output)
+	} else {
+		removed = append(removed, "systemd service updatectl (stopped and disabled)")
+	}
+
+	servicePath := "/etc/systemd/system/updatectl.service"
+	if removeFile(servicePath) {
+		removed = append(removed, servicePath)
+		if err := exec.Command("systemctl", "daemon-reload").Run(); err != nil {
+			fmt.Printf("Failed to reload systemd daemon: %v\n", err)
+		}
+	}
+	return removed
+}
+
+func uninstallSystemdUserService() []string {
+	var removed []string
+	disableCmd := exec.Command("systemctl", "--user", "disable", "--now", "updatectl")
+	if output, err := disableCmd.CombinedOutput(); err != nil {
+		fmt.Printf("Failed to stop and disable user service: %v\nOutput: %s\n", err, output)
+	} else {
+		removed = append(removed, "systemd user service updatectl (stopped and disabled)")
+	}
+
+	unitPath := systemdUserUnitPath()
+	if removeFile(unitPath) {
+		removed = append(removed, unitPath)
+		if err := exec.Command("systemctl", "--user", "daemon-reload").Run(); err != nil {
+			fmt.Printf("Failed to reload the systemd user instance: %v\n", err)
+		}
+	}
+	return removed
+}
+
+func uninstallLaunchAgent() []string {
+	var removed []string
+	plistPath := launchAgentPath()
+	domain := fmt.Sprintf("gui/%d", os.Getuid())
+	if err := exec.Command("launchctl", "bootout", domain, plistPath).Run(); err != nil {
+		// Fall back to the legacy subcommand on older macOS releases.
+		if err := exec.Command("launchctl", "unload", "-w", plistPath).Run(); err == nil {
+			removed = append(removed, "launch agent "+launchdLabel+" (unloaded)")
+		}
+	} else {
+		removed = append(removed, "launch agent "+launchdLabel+" (unloaded)")
+	}
+	if removeFile(plistPath) {
+		removed = append(removed, plistPath)
+	}
+	return removed
+}
+
+func uninstallWindowsTask() []string {
+	var removed []string
+	exec.Command("schtasks", "/End", "/TN", "updatectl").Run() // Ignore error if task isn't running
+	deleteCmd := exec.Command("schtasks", "/Delete", "/TN", "updatectl", "/F")
+	if output, err := deleteCmd.CombinedOutput(); err != nil {
+		fmt.Printf("Failed to delete scheduled task: %v\nOutput: %s\n", err, output)
+	} else {
+		removed = append(removed, "scheduled task updatectl")
+	}
+
+	batScriptPath := filepath.Join(os.Getenv("USERPROFILE"), "updatectl", "run_updatectl.bat")
+	if removeFile(batScriptPath) {
+		removed = append(removed, batScriptPath)
+	}
+	return removed
+}
+
+// windowsTaskExists reports whether the scheduled task set up by
+// 'updatectl init --task' exists.
+func windowsTaskExists() bool {
+	return exec.Command("schtasks", "/Query", "/TN", "updatectl").Run() == nil
+}
+
+// removeFile deletes path, reporting whether something was removed. A missing
+// file is not an error.
+func removeFile(path string) bool {
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("Failed to remove %s: %v\n", path, err)
+	}
+	return err == nil
+}
+
+// stdin is shared by every confirm call, so input buffered while answering
+// one prompt isn't lost to the next.
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on stdin, defaulting to no.
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N]: ", prompt)
+	line, _ := stdin.ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/parcoil/updatectl/pkg/updatectl"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [config-file]",
+	Short: "Check the config file for problems",
+	Long: `Check the config file for problems without running anything. The file is
+the one given as an argument, or else the one --config, $UPDATECTL_CONFIG or
+the platform default names. It is always read from disk, even inside a Docker
+container, where other commands build their config from the running
+containers.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := updatectl.ResolveConfigPath()
+		if len(args) == 1 {
+			path = args[0]
+		}
+		config, err := updatectl.ReadConfigFile(path)
+		if err == nil {
+			err = config.Validate()
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("✓ Config is valid:", path)
+	},
+}